@@ -1,49 +1,558 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ArshiaDadras/Ariadne/internal"
 	"github.com/ArshiaDadras/Ariadne/pkg"
 )
 
+type networkFlags struct {
+	network   *string
+	graphIn   *string
+	checksum  *bool
+	format    *string
+	profile   *string
+	schema    *string
+	delimiter *string
+	minSize   *int
+	action    *string
+	stats     *bool
+	snap      *float64
+	merge     *bool
+	mapping   *string
+	index     *string
+	names     *string
+	clashes   bool
+}
+
+func addNetworkFlags(flags *flag.FlagSet) *networkFlags {
+	return &networkFlags{
+		network:   flags.String("network", "data/road_network.csv", "road network file"),
+		graphIn:   flags.String("graph-in", "", "load a previously saved graph (json, ndjson or .ariadne binary) instead of building the road network"),
+		checksum:  flags.Bool("verify-checksum", false, "verify the checksum of binary graphs loaded with -graph-in"),
+		format:    flags.String("network-format", internal.FormatTSV, "road network format: tsv, csv, geojson, osm or pbf"),
+		profile:   flags.String("profile", "car", "OpenStreetMap filtering profile: car, bike or foot"),
+		schema:    flags.String("schema", "", "JSON schema describing the columns of tsv/csv inputs"),
+		delimiter: flags.String("delimiter", "", "field delimiter of tsv/csv inputs, overriding the schema"),
+		minSize:   flags.Int("min-component", 0, "strongly connected components with fewer nodes are dropped or flagged (0 disables)"),
+		action:    flags.String("component-action", "flag", "what to do with small components: drop or flag"),
+		stats:     flags.Bool("component-stats", false, "log strongly connected component statistics"),
+		snap:      flags.Float64("snap-tolerance", 0, "merge nodes closer than this many meters (0 disables)"),
+		merge:     flags.Bool("merge-chains", false, "merge chains of pass-through nodes into single edges"),
+		mapping:   flags.String("simplify-mapping", "", "optional output of the old to new node and edge IDs after simplification"),
+		names:     flags.String("geojson-properties", "", "GeoJSON network property names by field, e.g. id=osm_id,speed=maxspeed (fields: id, from, to, speed, oneway, class, name, lanes, access, surface)"),
+		index:     flags.String("index", "", "spatial index for candidate lookups: segment or rtree (default segment, or the R-tree stored in a binary graph)"),
+	}
+}
+
+type matchFlags struct {
+	classPriors *string
+	speedAware  *bool
+	uTurn       *float64
+	jitter      *float64
+}
+
+func addMatchFlags(flags *flag.FlagSet) *matchFlags {
+	return &matchFlags{
+		classPriors: flags.String("class-prior", "", "emission priors by road class, e.g. motorway=2,primary=1.5"),
+		speedAware:  flags.Bool("speed-aware", false, "penalize transitions faster than the edge speeds at the GPS timestamps"),
+		uTurn:       flags.Float64("u-turn-penalty", internal.DefaultMatchOptions.UTurnPenalty, "meters added to transitions that turn back"),
+		jitter:      flags.Float64("jitter-tolerance", internal.DefaultMatchOptions.JitterTolerance, "meters a point may fall back along the same edge before it counts as a U-turn"),
+	}
+}
+
+func (f *matchFlags) options() internal.MatchOptions {
+	options := internal.DefaultMatchOptions
+	options.SpeedAware, options.UTurnPenalty, options.JitterTolerance = *f.speedAware, *f.uTurn, *f.jitter
+	if *f.classPriors != "" {
+		priors, err := parseWeights(*f.classPriors)
+		if err != nil {
+			log.Fatalf("Error parsing class priors: %v", err)
+		}
+		options.ClassPriors = priors
+	}
+	return options
+}
+
+func (f *networkFlags) simplify(graph *pkg.Graph) *pkg.Graph {
+	if *f.snap <= 0 && !*f.merge {
+		return graph
+	}
+
+	simplified, mapping, err := pkg.Simplify(graph, pkg.SimplifyOptions{SnapTolerance: *f.snap, MergeChains: *f.merge})
+	if err != nil {
+		log.Fatalf("Error simplifying graph: %v", err)
+	}
+	log.Printf("Graph simplified from %d nodes and %d edges to %d nodes and %d edges", len(graph.Nodes), len(graph.Edges), len(simplified.Nodes), len(simplified.Edges))
+
+	if *f.mapping != "" {
+		if err := internal.SaveObject(mapping, *f.mapping); err != nil {
+			log.Fatalf("Error writing simplification mapping: %v", err)
+		}
+		log.Println("Simplification mapping written successfully")
+	}
+	return simplified
+}
+
+func (f *networkFlags) pruneComponents(graph *pkg.Graph) {
+	if *f.stats {
+		stats := pkg.NewComponentStats(graph.StronglyConnectedComponents())
+		log.Printf("Graph has %d strongly connected components (largest %d nodes, %d singletons)", stats.Components, stats.Largest, stats.Singletons)
+	}
+	if *f.minSize <= 0 {
+		return
+	}
+
+	switch *f.action {
+	case "drop":
+		removed, err := graph.DropSmallComponents(*f.minSize)
+		if err != nil {
+			log.Fatalf("Error dropping small components: %v", err)
+		}
+		log.Printf("Dropped %d nodes in components smaller than %d", len(removed), *f.minSize)
+	case "flag":
+		flagged := graph.FlagSmallComponents(*f.minSize)
+		log.Printf("Flagged %d edges in components smaller than %d", len(flagged), *f.minSize)
+	default:
+		log.Fatalf("Unknown component action: %s", *f.action)
+	}
+}
+
+func (f *networkFlags) loadSchema() internal.Schema {
+	schema := internal.DefaultSchema
+	if *f.schema != "" {
+		var err error
+		if schema, err = internal.LoadSchema(*f.schema); err != nil {
+			log.Fatalf("Error loading schema: %v", err)
+		}
+	}
+	if *f.delimiter != "" {
+		schema.Delimiter = *f.delimiter
+	}
+	return schema
+}
+
+func (f *networkFlags) properties() *internal.GeoJSONNetworkProperties {
+	properties := internal.DefaultGeoJSONNetworkProperties
+	if err := parseProperties(*f.names, properties.Rename); err != nil {
+		log.Fatalf("Error parsing GeoJSON properties: %v", err)
+	}
+	return &properties
+}
+
+func traceProperties(value string) internal.GeoJSONTraceProperties {
+	properties := internal.DefaultGeoJSONTraceProperties
+	if err := parseProperties(value, properties.Rename); err != nil {
+		log.Fatalf("Error parsing GeoJSON trace properties: %v", err)
+	}
+	return properties
+}
+
+func (f *networkFlags) loadGraph(schema internal.Schema) *pkg.Graph {
+	var graph *pkg.Graph
+	if *f.graphIn != "" {
+		var err error
+		internal.VerifyChecksum = *f.checksum
+		if graph, err = internal.LoadGraphWith(*f.graphIn, f.clashes, *f.index); err != nil {
+			log.Fatalf("Error loading graph: %v", err)
+		}
+		log.Println("Graph loaded successfully")
+	} else {
+		graph = pkg.NewGraph()
+		if err := internal.BuildNetwork(graph, *f.network, internal.NetworkOptions{
+			Format:           *f.format,
+			RemoveDuplicates: true,
+			RecordClashes:    f.clashes,
+			Profile:          *f.profile,
+			Schema:           schema,
+			Properties:       f.properties(),
+		}); err != nil {
+			log.Fatalf("Error building road network: %v", err)
+		}
+		log.Println("Graph created successfully")
+	}
+
+	graph = f.simplify(graph)
+	f.pruneComponents(graph)
+	if graph.Index == nil || (*f.index != "" && internal.IndexKind(graph.Index) != *f.index) {
+		if err := internal.PreprocessIndex(graph, *f.index); err != nil {
+			log.Fatalf("Error preprocessing graph: %v", err)
+		}
+		log.Println("Graph preprocessed successfully")
+	}
+
+	if parallel := graph.ParallelEdges(); len(parallel) > 0 {
+		log.Printf("Graph has %d node pairs joined by parallel edges (e.g. %s)", len(parallel), parallel[0][0].ID)
+	}
+	return graph
+}
+
 func main() {
-	graph := pkg.NewGraph()
-	if err := internal.BuildRoadNetwork(graph, "data/road_network.csv", true); err != nil {
-		log.Fatalf("Error building road network: %v", err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			validate(os.Args[2:])
+			return
+		case "extract":
+			extract(os.Args[2:])
+			return
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "route":
+			route(os.Args[2:])
+			return
+		case "match":
+			match(os.Args[2:])
+			return
+		}
+	}
+	match(os.Args[1:])
+}
+
+func validate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	network := addNetworkFlags(flags)
+	geojsonPath := flags.String("geojson", "", "optional GeoJSON output of the offending edges and nodes")
+	reportPath := flags.String("report", "", "optional JSON output of the validation report")
+	flags.Parse(args)
+
+	network.clashes = true
+	graph := network.loadGraph(network.loadSchema())
+	report := graph.Validate()
+
+	fmt.Printf("%d nodes, %d edges\n", report.Nodes, report.Edges)
+	if report.Valid() {
+		fmt.Println("no problems found")
+	}
+	for _, issue := range report.Issues {
+		fmt.Printf("%-22s %8d  e.g. %s\n", issue.Kind, issue.Count, strings.Join(issue.Examples, ", "))
+	}
+
+	if *reportPath != "" {
+		if err := internal.SaveObject(report, *reportPath); err != nil {
+			log.Fatalf("Error writing validation report: %v", err)
+		}
+		log.Println("Validation report written successfully")
+	}
+	if *geojsonPath != "" {
+		if err := internal.SaveValidationGeoJSON(report, *geojsonPath); err != nil {
+			log.Fatalf("Error writing GeoJSON: %v", err)
+		}
+		log.Println("GeoJSON written successfully")
+	}
+}
+
+func parseWeights(value string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for _, pair := range strings.Split(value, ",") {
+		key, weight, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected class=weight, got %q", pair)
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil {
+			return nil, err
+		}
+		weights[strings.TrimSpace(key)] = parsed
+	}
+	return weights, nil
+}
+
+func parseProperties(value string, rename func(map[string]string) error) error {
+	if value == "" {
+		return nil
+	}
+	names := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		field, name, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected field=property, got %q", pair)
+		}
+		names[strings.TrimSpace(field)] = strings.TrimSpace(name)
+	}
+	return rename(names)
+}
+
+func parseBoundingBox(value string) (pkg.BoundingBox, error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != 4 {
+		return pkg.BoundingBox{}, fmt.Errorf("%w: expected minLon,minLat,maxLon,maxLat", pkg.ErrInvalidRegion)
 	}
-	log.Println("Graph created successfully")
 
-	points, err := internal.ParseGPSData("data/gps_data.csv")
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		var err error
+		if values[i], err = strconv.ParseFloat(strings.TrimSpace(field), 64); err != nil {
+			return pkg.BoundingBox{}, err
+		}
+	}
+	return pkg.NewBoundingBox(pkg.Point{Longitude: values[0], Latitude: values[1]}, pkg.Point{Longitude: values[2], Latitude: values[3]}), nil
+}
+
+func extract(args []string) {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	network := addNetworkFlags(flags)
+	bbox := flags.String("bbox", "", "extract by bounding box: minLon,minLat,maxLon,maxLat")
+	polygonPath := flags.String("polygon", "", "extract by the first Polygon feature of a GeoJSON file")
+	corridorPath := flags.String("corridor", "", "extract by a buffered corridor around the traces of a GPS file")
+	gpsFormat := flags.String("gps-format", internal.FormatTSV, "corridor trace format: tsv, csv, gpx, nmea or geojson")
+	traceNames := flags.String("geojson-trace-properties", "", "GeoJSON trace property names by field, e.g. timestamps=times,time=recorded_at")
+	buffer := flags.Float64("buffer", 200, "corridor buffer in meters")
+	mode := flags.String("mode", pkg.ExtractKeep, "edges crossing the boundary: keep or clip")
+	outputPath := flags.String("output", "data/extract.json", "extracted graph output file; .ndjson/.jsonl selects NDJSON, .ariadne the binary format and .gz compresses")
+	compact := flags.Bool("compact", false, "write JSON output without indentation")
+	flags.Parse(args)
+
+	schema := network.loadSchema()
+	var region pkg.Region
+	switch {
+	case *bbox != "":
+		box, err := parseBoundingBox(*bbox)
+		if err != nil {
+			log.Fatalf("Error parsing bounding box: %v", err)
+		}
+		region = box
+	case *polygonPath != "":
+		polygon, err := internal.ReadGeoJSONPolygon(*polygonPath)
+		if err != nil {
+			log.Fatalf("Error reading polygon: %v", err)
+		}
+		region = polygon
+	case *corridorPath != "":
+		traces, err := internal.ParseTraces(*corridorPath, *gpsFormat, schema, traceProperties(*traceNames))
+		if err != nil {
+			log.Fatalf("Error parsing GPS data: %v", err)
+		}
+		corridors := make(pkg.Union, 0, len(traces))
+		for _, trace := range traces {
+			corridor := &pkg.Corridor{Buffer: *buffer}
+			for _, point := range trace {
+				corridor.Trace = append(corridor.Trace, point.Location)
+			}
+			if len(corridor.Trace) > 0 {
+				corridors = append(corridors, corridor)
+			}
+		}
+		region = corridors
+	default:
+		log.Fatal("One of -bbox, -polygon or -corridor is required")
+	}
+
+	graph := network.loadGraph(schema)
+	extracted, err := pkg.Extract(graph, region, *mode)
 	if err != nil {
-		log.Fatalf("Error parsing GPS data: %v", err)
+		log.Fatalf("Error extracting subgraph: %v", err)
+	}
+	log.Printf("Extracted %d nodes and %d edges", len(extracted.Nodes), len(extracted.Edges))
+
+	if err := internal.SaveObjectWith(extracted, *outputPath, internal.OutputOptionsFor(*outputPath, *compact)); err != nil {
+		log.Fatalf("Error writing extracted graph: %v", err)
+	}
+	log.Println("Extracted graph written successfully")
+}
+
+func loadTraffic(graph *pkg.Graph, speedProfiles, overrides string) {
+	if speedProfiles != "" {
+		applied, unknown, err := internal.LoadSpeedProfiles(graph, speedProfiles)
+		if err != nil {
+			log.Fatalf("Error loading speed profiles: %v", err)
+		}
+		log.Printf("Speed profiles loaded for %d edges", applied)
+		if unknown > 0 {
+			log.Printf("Skipped speed profiles for %d edges not in the graph", unknown)
+		}
+	}
+	if overrides != "" {
+		count, err := internal.LoadOverrides(graph, overrides)
+		if err != nil {
+			log.Fatalf("Error loading overrides: %v", err)
+		}
+		log.Printf("%d edge overrides loaded", count)
+	}
+}
+
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	network := addNetworkFlags(flags)
+	addr := flags.String("addr", ":8080", "HTTP listen address")
+	speedProfiles := flags.String("speed-profiles", "", "JSON file of per-edge speed profiles by time of week")
+	overrides := flags.String("overrides", "", "JSON file of edge overrides (closures, speeds and penalties)")
+	matching := addMatchFlags(flags)
+	flags.Parse(args)
+
+	options := matching.options()
+	graph := network.loadGraph(network.loadSchema())
+	loadTraffic(graph, *speedProfiles, *overrides)
+
+	log.Printf("Listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, internal.NewServer(graph, options).Handler()); err != nil {
+		log.Fatalf("Error serving: %v", err)
+	}
+}
+
+func route(args []string) {
+	flags := flag.NewFlagSet("route", flag.ExitOnError)
+	network := addNetworkFlags(flags)
+	from := flags.String("from", "", "start node ID")
+	to := flags.String("to", "", "end node ID")
+	depart := flags.String("depart", "", "departure time in RFC3339 (default now)")
+	speedProfiles := flags.String("speed-profiles", "", "JSON file of per-edge speed profiles by time of week")
+	overrides := flags.String("overrides", "", "JSON file of edge overrides (closures, speeds and penalties)")
+	flags.Parse(args)
+
+	departure := time.Now()
+	if *depart != "" {
+		var err error
+		if departure, err = time.Parse(time.RFC3339, *depart); err != nil {
+			log.Fatalf("Error parsing departure time: %v", err)
+		}
+	}
+
+	graph := network.loadGraph(network.loadSchema())
+	loadTraffic(graph, *speedProfiles, *overrides)
+
+	start, err := graph.GetNode(*from)
+	if err != nil {
+		log.Fatalf("Error finding start node %q: %v", *from, err)
+	}
+	end, err := graph.GetNode(*to)
+	if err != nil {
+		log.Fatalf("Error finding end node %q: %v", *to, err)
+	}
+
+	path, duration, err := graph.FastestPath(start, end, departure)
+	if err != nil {
+		log.Fatalf("Error routing: %v", err)
+	}
+	duration = duration.Round(time.Second)
+	ids := make([]string, 0, len(path))
+	for _, edge := range path {
+		ids = append(ids, edge.ID)
+	}
+	fmt.Printf("departure %s, arrival %s, travel time %s\n", departure.Format(time.RFC3339), departure.Add(duration).Format(time.RFC3339), duration)
+	fmt.Println(strings.Join(ids, " "))
+}
+
+func match(args []string) {
+	flags := flag.NewFlagSet("match", flag.ExitOnError)
+	network := addNetworkFlags(flags)
+	gpsPath := flags.String("gps", "data/gps_data.csv", "GPS trace file")
+	gpsFormat := flags.String("gps-format", internal.FormatTSV, "GPS trace format: tsv, csv, gpx, nmea or geojson")
+	traceNames := flags.String("geojson-trace-properties", "", "GeoJSON trace property names by field, e.g. timestamps=times,time=recorded_at")
+	printSchema := flags.Bool("print-schema", false, "print the default schema and exit")
+	outputFormat := flags.String("output-format", internal.FormatJSON, "match output format: json, geojson or gpx")
+	outputPath := flags.String("output", "", "match output file for geojson and gpx (default data/match.<format>)")
+	pointsPath := flags.String("points", "data/points.json", "points output file; .ndjson/.jsonl selects NDJSON and .gz compresses")
+	edgesPath := flags.String("edges", "data/edges.json", "matched edges output file; .ndjson/.jsonl selects NDJSON and .gz compresses")
+	graphOutPath := flags.String("graph-out", "data/graph.json", "graph output file; .ndjson/.jsonl selects NDJSON, .ariadne the binary format and .gz compresses")
+	recordsPath := flags.String("records", "", "optional per-point match records output file")
+	compact := flags.Bool("compact", false, "write JSON outputs without indentation")
+	csr := flags.Bool("csr", false, "route on a compressed sparse row copy of the graph")
+	classCosts := flags.String("class-cost", "", "routing cost factors by road class, e.g. residential=1.5,service=3")
+	speedProfiles := flags.String("speed-profiles", "", "JSON file of per-edge speed profiles by time of week")
+	overrides := flags.String("overrides", "", "JSON file of edge overrides (closures, speeds and penalties)")
+	matching := addMatchFlags(flags)
+	flags.Parse(args)
+
+	switch *outputFormat {
+	case internal.FormatJSON, internal.FormatGeoJSON, internal.FormatGPX:
+	default:
+		log.Fatalf("Unknown output format: %s", *outputFormat)
+	}
+	if *outputPath == "" {
+		*outputPath = "data/match." + *outputFormat
+	}
+
+	if *printSchema {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(internal.DefaultSchema); err != nil {
+			log.Fatalf("Error printing schema: %v", err)
+		}
+		return
 	}
-	log.Println("GPS data parsed successfully")
 
-	points = internal.RemoveNearbyPoints(points)
-	log.Println("Nearby points removed successfully")
+	schema := network.loadSchema()
+	graph := network.loadGraph(schema)
 
-	internal.Preprocess(graph)
-	log.Println("Graph preprocessed successfully")
+	options := matching.options()
+	if *classCosts != "" {
+		factors, err := parseWeights(*classCosts)
+		if err != nil {
+			log.Fatalf("Error parsing class costs: %v", err)
+		}
+		graph.SetCost(pkg.ClassCost(factors))
+	}
+	loadTraffic(graph, *speedProfiles, *overrides)
+
+	if *csr {
+		graph.Router = pkg.NewCSRGraph(graph)
+		log.Println("CSR router built successfully")
+	}
 
-	edges, err := internal.MapMatch(graph, points)
+	traces, err := internal.ParseTraces(*gpsPath, *gpsFormat, schema, traceProperties(*traceNames))
 	if err != nil {
-		log.Fatalf("Error map matching: %v", err)
+		log.Fatalf("Error parsing GPS data: %v", err)
+	}
+	log.Printf("GPS data parsed successfully (%d traces)", len(traces))
+
+	results := make([]*internal.MatchResult, 0, len(traces))
+	points, edges := make([]internal.GPSPoint, 0), make([]*pkg.Edge, 0)
+	for _, input := range traces {
+		match, positions, err := internal.MapMatch(graph, input, options)
+		if err != nil {
+			log.Fatalf("Error map matching: %v", err)
+		}
+
+		trace := internal.RemoveNearbyPoints(input)
+		results = append(results, internal.NewMatchResult(input, match, positions))
+		points, edges = append(points, trace...), append(edges, match...)
 	}
 	log.Println("Map matching completed successfully")
 
-	if err := internal.SaveObject(points, "data/points.json"); err != nil {
+	switch *outputFormat {
+	case internal.FormatGeoJSON:
+		if err := internal.SaveMatchGeoJSON(results, *outputPath); err != nil {
+			log.Fatalf("Error writing GeoJSON: %v", err)
+		}
+		log.Println("GeoJSON written successfully")
+		return
+	case internal.FormatGPX:
+		if err := internal.SaveMatchGPX(results, *outputPath); err != nil {
+			log.Fatalf("Error writing GPX: %v", err)
+		}
+		log.Println("GPX written successfully")
+		return
+	}
+
+	if err := internal.SaveObjectWith(points, *pointsPath, internal.OutputOptionsFor(*pointsPath, *compact)); err != nil {
 		log.Fatalf("Error writing points: %v", err)
 	}
 	log.Println("Points written successfully")
-	if err := internal.SaveObject(edges, "data/edges.json"); err != nil {
+	if err := internal.SaveObjectWith(edges, *edgesPath, internal.OutputOptionsFor(*edgesPath, *compact)); err != nil {
 		log.Fatalf("Error writing edges: %v", err)
 	}
 	log.Println("Edges written successfully")
-	if err := internal.SaveObject(graph, "data/graph.json"); err != nil {
+	if err := internal.SaveObjectWith(graph, *graphOutPath, internal.OutputOptionsFor(*graphOutPath, *compact)); err != nil {
 		log.Fatalf("Error writing graph: %v", err)
 	}
 	log.Println("Graph written successfully")
+	if *recordsPath != "" {
+		if err := internal.SaveObjectWith(results, *recordsPath, internal.OutputOptionsFor(*recordsPath, *compact)); err != nil {
+			log.Fatalf("Error writing match records: %v", err)
+		}
+		log.Println("Match records written successfully")
+	}
 
 	log.Println("All tasks completed successfully")
 }
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+const (
+	FormatBinary    = "ariadne"
+	BinaryExtension = ".ariadne"
+)
+
+var (
+	ErrNotGraph = errors.New("binary output requires a graph")
+)
+
+var VerifyChecksum = false
+
+func isBinaryGraph(path string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(path, ".gz"), BinaryExtension)
+}
+
+func writeBinary(w io.Writer, obj interface{}) error {
+	graph, ok := obj.(*pkg.Graph)
+	if !ok {
+		return fmt.Errorf("%w, got %T", ErrNotGraph, obj)
+	}
+	return graph.WriteBinary(w)
+}
+
+func readCompressedBinary(path string) ([]byte, error) {
+	reader, closeInput, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInput()
+	return io.ReadAll(reader)
+}
+
+func LoadBinaryGraph(path string) (*pkg.Graph, error) {
+	if strings.HasSuffix(path, ".gz") {
+		data, err := readCompressedBinary(path)
+		if err != nil {
+			return nil, err
+		}
+		return pkg.ReadBinaryGraph(data, VerifyChecksum)
+	}
+
+	data, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := pkg.ReadBinaryGraph(data, VerifyChecksum)
+	if err = errors.Join(err, unmapFile(data)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return graph, nil
+}
@@ -2,11 +2,11 @@ package internal
 
 import (
 	"encoding/csv"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"slices"
 	"strconv"
-	"time"
 
 	"github.com/ArshiaDadras/Ariadne/pkg"
 )
@@ -15,76 +15,190 @@ const (
 	TimeFormat = "02-Jan-2006 15:04:05"
 )
 
-func SaveObject(obj interface{}, path string) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
+const (
+	FormatTSV     = "tsv"
+	FormatCSV     = "csv"
+	FormatGPX     = "gpx"
+	FormatNMEA    = "nmea"
+	FormatGeoJSON = "geojson"
+	FormatOSM     = "osm"
+	FormatPBF     = "pbf"
+)
 
-	b, err := json.MarshalIndent(obj, "", "  ")
-	if err != nil {
-		return err
+var (
+	ErrUnknownFormat = errors.New("unknown format")
+	ErrMissingColumn = errors.New("missing column")
+)
+
+type ParseError struct {
+	Row    int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.Row > 0 && e.Column > 0:
+		return fmt.Sprintf("row %d, column %d: %v", e.Row, e.Column, e.Err)
+	case e.Row > 0:
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	case e.Column > 0:
+		return fmt.Sprintf("column %d: %v", e.Column, e.Err)
 	}
+	return e.Err.Error()
+}
 
-	_, err = file.Write(b)
-	if err != nil {
-		return err
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func columnError(column int, err error) error {
+	return &ParseError{Column: column + 1, Err: err}
+}
+
+func withRow(err error, row int) error {
+	var parseError *ParseError
+	if errors.As(err, &parseError) {
+		parseError.Row = row
+		return parseError
 	}
+	return &ParseError{Row: row, Err: err}
+}
 
-	return nil
+func SaveObject(obj interface{}, path string) error {
+	return SaveObjectWith(obj, path, OutputOptionsFor(path, false))
 }
 
-func ParseCSV(path string) ([][]string, error) {
+func ReadTable(path string, delimiter rune, header bool) ([]string, [][]string, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	defer file.Close()
 
 	reader := csv.NewReader(file)
 	reader.FieldsPerRecord = -1
-	reader.Comma = '\t'
+	reader.Comma = delimiter
+	reader.LazyQuotes = true
 
 	data, err := reader.ReadAll()
-	return data[1:], err
+	if err != nil {
+		return nil, nil, err
+	}
+	if !header || len(data) == 0 {
+		return nil, data, nil
+	}
+	return data[0], data[1:], nil
+}
+
+func ParseCSV(path string) ([][]string, error) {
+	_, data, err := ReadTable(path, '\t', true)
+	return data, err
 }
 
 func ParseGPSData(path string) ([]GPSPoint, error) {
-	data, err := ParseCSV(path)
+	return ParseGPSDataWithSchema(path, FormatTSV, DefaultSchema)
+}
+
+func ParseGPSDataWithSchema(path, format string, schema Schema) ([]GPSPoint, error) {
+	delimiter, err := schema.delimiter(format)
+	if err != nil {
+		return nil, err
+	}
+	header, data, err := ReadTable(path, delimiter, schema.HasHeader())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireColumns(map[string]string{"latitude": schema.Trace.Latitude, "longitude": schema.Trace.Longitude, "time": schema.Trace.Time}); err != nil {
+		return nil, err
+	}
+	columns, err := resolveColumns(header, schema.Trace.Latitude, schema.Trace.Longitude, schema.Trace.Time, schema.Trace.Date)
 	if err != nil {
 		return nil, err
 	}
 
+	firstRow := 1
+	if schema.HasHeader() {
+		firstRow = 2
+	}
+
 	points := make([]GPSPoint, 0, len(data))
-	for _, row := range data {
-		latitude, err := strconv.ParseFloat(row[2], 64)
+	for i, row := range data {
+		point, err := parseGPSRow(row, columns, schema.Trace.TimeFormat)
 		if err != nil {
-			return nil, err
+			return nil, withRow(err, i+firstRow)
 		}
+		points = append(points, point)
+	}
 
-		longitude, err := strconv.ParseFloat(row[3], 64)
-		if err != nil {
-			return nil, err
+	SortByTime(points)
+	return points, nil
+}
+
+func parseGPSRow(row []string, columns []int, timeFormat string) (GPSPoint, error) {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		if column < 0 {
+			continue
 		}
 
-		dateTime, err := time.Parse(TimeFormat, row[0]+" "+row[1])
+		value, err := cell(row, column)
 		if err != nil {
-			return nil, err
+			return GPSPoint{}, err
 		}
+		values[i] = value
+	}
 
-		points = append(points, GPSPoint{
-			Location: pkg.Point{Longitude: longitude, Latitude: latitude},
-			Time:     dateTime,
-		})
+	latitude, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return GPSPoint{}, columnError(columns[0], err)
 	}
 
-	slices.SortFunc(points, func(a, b GPSPoint) int {
-		if a.Time.Before(b.Time) {
-			return -1
-		} else if a.Time.After(b.Time) {
-			return 1
-		}
-		return 0
+	longitude, err := strconv.ParseFloat(values[1], 64)
+	if err != nil {
+		return GPSPoint{}, columnError(columns[1], err)
+	}
+
+	value := values[2]
+	if columns[3] >= 0 {
+		value = values[3] + " " + values[2]
+	}
+	dateTime, err := ParseTime(value, timeFormat)
+	if err != nil {
+		return GPSPoint{}, columnError(columns[2], err)
+	}
+
+	return GPSPoint{
+		Location: pkg.Point{Longitude: longitude, Latitude: latitude},
+		Time:     dateTime,
+	}, nil
+}
+
+func SortByTime(points []GPSPoint) {
+	slices.SortStableFunc(points, func(a, b GPSPoint) int {
+		return a.Time.Compare(b.Time)
 	})
+}
 
-	return points, nil
+func ParseTraces(path, format string, schema Schema, properties GeoJSONTraceProperties) ([][]GPSPoint, error) {
+	switch format {
+	case FormatTSV, FormatCSV:
+		points, err := ParseGPSDataWithSchema(path, format, schema)
+		if err != nil {
+			return nil, err
+		}
+		return [][]GPSPoint{points}, nil
+	case FormatGPX:
+		return ParseGPX(path)
+	case FormatNMEA:
+		points, err := ParseNMEA(path)
+		if err != nil {
+			return nil, err
+		}
+		return [][]GPSPoint{points}, nil
+	case FormatGeoJSON:
+		return ParseGeoJSONTraces(path, properties)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
 }
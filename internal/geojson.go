@@ -0,0 +1,408 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+var (
+	ErrInvalidGeometry = errors.New("invalid geometry")
+	ErrMissingProperty = errors.New("missing property")
+	ErrUnknownProperty = errors.New("unknown property")
+)
+
+type GeoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   *GeoJSONGeometry       `json:"geometry"`
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONNetworkProperties struct {
+	ID      string
+	From    string
+	To      string
+	Speed   string
+	Oneway  string
+	Class   string
+	Name    string
+	Lanes   string
+	Access  string
+	Surface string
+}
+
+type GeoJSONTraceProperties struct {
+	Timestamps string
+	Time       string
+}
+
+var (
+	DefaultGeoJSONNetworkProperties = GeoJSONNetworkProperties{
+		ID:      "id",
+		From:    "from",
+		To:      "to",
+		Speed:   "speed",
+		Oneway:  "oneway",
+		Class:   "class",
+		Name:    "name",
+		Lanes:   "lanes",
+		Access:  "access",
+		Surface: "surface",
+	}
+	DefaultGeoJSONTraceProperties = GeoJSONTraceProperties{
+		Timestamps: "timestamps",
+		Time:       "time",
+	}
+)
+
+func renameProperties(fields map[string]*string, names map[string]string) error {
+	for _, field := range sortedKeys(names) {
+		target, ok := fields[field]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownProperty, field)
+		}
+		*target = names[field]
+	}
+	return nil
+}
+
+func (p *GeoJSONNetworkProperties) Rename(names map[string]string) error {
+	return renameProperties(map[string]*string{
+		"id":      &p.ID,
+		"from":    &p.From,
+		"to":      &p.To,
+		"speed":   &p.Speed,
+		"oneway":  &p.Oneway,
+		"class":   &p.Class,
+		"name":    &p.Name,
+		"lanes":   &p.Lanes,
+		"access":  &p.Access,
+		"surface": &p.Surface,
+	}, names)
+}
+
+func (p *GeoJSONTraceProperties) Rename(names map[string]string) error {
+	return renameProperties(map[string]*string{
+		"timestamps": &p.Timestamps,
+		"time":       &p.Time,
+	}, names)
+}
+
+func ReadGeoJSON(path string) (*GeoJSONFeatureCollection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var collection GeoJSONFeatureCollection
+	if err := json.NewDecoder(file).Decode(&collection); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func toPoint(coordinates []float64) (pkg.Point, error) {
+	if len(coordinates) < 2 {
+		return pkg.Point{}, ErrInvalidGeometry
+	}
+	return pkg.Point{Longitude: coordinates[0], Latitude: coordinates[1]}, nil
+}
+
+func (g *GeoJSONGeometry) Point() (pkg.Point, error) {
+	var coordinates []float64
+	if g.Type != "Point" {
+		return pkg.Point{}, fmt.Errorf("%w: expected Point, got %s", ErrInvalidGeometry, g.Type)
+	}
+	if err := json.Unmarshal(g.Coordinates, &coordinates); err != nil {
+		return pkg.Point{}, err
+	}
+	return toPoint(coordinates)
+}
+
+func (g *GeoJSONGeometry) LineString() ([]pkg.Point, error) {
+	var coordinates [][]float64
+	if g.Type != "LineString" {
+		return nil, fmt.Errorf("%w: expected LineString, got %s", ErrInvalidGeometry, g.Type)
+	}
+	if err := json.Unmarshal(g.Coordinates, &coordinates); err != nil {
+		return nil, err
+	}
+	if len(coordinates) < 2 {
+		return nil, fmt.Errorf("%w: LineString needs at least two positions", ErrInvalidGeometry)
+	}
+
+	points := make([]pkg.Point, 0, len(coordinates))
+	for _, position := range coordinates {
+		point, err := toPoint(position)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+func (g *GeoJSONGeometry) Polygon() (pkg.Polygon, error) {
+	var rings [][][]float64
+	if g.Type != "Polygon" {
+		return nil, fmt.Errorf("%w: expected Polygon, got %s", ErrInvalidGeometry, g.Type)
+	}
+	if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+		return nil, err
+	}
+	if len(rings) == 0 || len(rings[0]) < 4 {
+		return nil, fmt.Errorf("%w: Polygon needs a ring of at least four positions", ErrInvalidGeometry)
+	} else if len(rings) > 1 {
+		return nil, fmt.Errorf("%w: Polygon holes are not supported", ErrInvalidGeometry)
+	}
+
+	polygon := make(pkg.Polygon, 0, len(rings[0]))
+	for _, position := range rings[0] {
+		point, err := toPoint(position)
+		if err != nil {
+			return nil, err
+		}
+		polygon = append(polygon, point)
+	}
+	if err := polygon.Validate(); err != nil {
+		return nil, err
+	}
+	return polygon, nil
+}
+
+func ReadGeoJSONPolygon(path string) (pkg.Polygon, error) {
+	collection, err := ReadGeoJSON(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, feature := range collection.Features {
+		if feature.Geometry != nil && feature.Geometry.Type == "Polygon" {
+			return feature.Geometry.Polygon()
+		}
+	}
+	return nil, fmt.Errorf("%w: no Polygon feature in %s", ErrInvalidGeometry, path)
+}
+
+func propertyString(properties map[string]interface{}, name string) (string, bool) {
+	switch value := properties[name].(type) {
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
+	return "", false
+}
+
+func propertyFloat(properties map[string]interface{}, name string) (float64, error) {
+	switch value := properties[name].(type) {
+	case float64:
+		return value, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
+	}
+	return 0, fmt.Errorf("%w: %s", ErrMissingProperty, name)
+}
+
+func parseOneway(value string) (forward, backward bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes":
+		return true, false
+	case "-1", "reverse":
+		return false, true
+	}
+	return true, true
+}
+
+func coordinateID(point pkg.Point) string {
+	return strconv.FormatFloat(point.Longitude, 'f', -1, 64) + "," + strconv.FormatFloat(point.Latitude, 'f', -1, 64)
+}
+
+func geoJSONAttributes(values map[string]interface{}, properties GeoJSONNetworkProperties) (*pkg.Attributes, error) {
+	attributes := &pkg.Attributes{}
+	attributes.Class, _ = propertyString(values, properties.Class)
+	attributes.Name, _ = propertyString(values, properties.Name)
+	attributes.Access, _ = propertyString(values, properties.Access)
+	attributes.Surface, _ = propertyString(values, properties.Surface)
+
+	if lanes, ok := propertyString(values, properties.Lanes); ok {
+		var err error
+		if attributes.Lanes, err = parseLanes(lanes); err != nil {
+			return nil, fmt.Errorf("property %s: %w", properties.Lanes, err)
+		}
+	}
+	if attributes.Empty() {
+		return nil, nil
+	}
+	return attributes, nil
+}
+
+func BuildGeoJSONNetwork(graph *pkg.Graph, path string, properties GeoJSONNetworkProperties, removeDuplicates bool) error {
+	collection, err := ReadGeoJSON(path)
+	if err != nil {
+		return err
+	}
+
+	var mp map[pkg.Point]string = nil
+	if removeDuplicates {
+		mp = make(map[pkg.Point]string)
+	}
+
+	for i, feature := range collection.Features {
+		if feature.Geometry == nil || feature.Geometry.Type != "LineString" {
+			continue
+		}
+		points, err := feature.Geometry.LineString()
+		if err != nil {
+			return fmt.Errorf("feature %d: %w", i, err)
+		}
+
+		id, ok := propertyString(feature.Properties, properties.ID)
+		if !ok {
+			id = strconv.Itoa(i)
+		}
+		from, ok := propertyString(feature.Properties, properties.From)
+		if !ok {
+			from = coordinateID(points[0])
+		}
+		to, ok := propertyString(feature.Properties, properties.To)
+		if !ok {
+			to = coordinateID(points[len(points)-1])
+		}
+
+		speed, err := propertyFloat(feature.Properties, properties.Speed)
+		if err != nil {
+			return fmt.Errorf("feature %d: %w", i, err)
+		}
+		speed *= 1000.0 / 3600.0
+
+		start, err := getOrCreateNode(graph, from, points[0], mp)
+		if err != nil {
+			return fmt.Errorf("feature %d: %w", i, err)
+		}
+		end, err := getOrCreateNode(graph, to, points[len(points)-1], mp)
+		if err != nil {
+			return fmt.Errorf("feature %d: %w", i, err)
+		}
+
+		attributes, err := geoJSONAttributes(feature.Properties, properties)
+		if err != nil {
+			return fmt.Errorf("feature %d: %w", i, err)
+		}
+
+		var edges []*pkg.Edge
+		oneway, _ := propertyString(feature.Properties, properties.Oneway)
+		switch forward, backward := parseOneway(oneway); {
+		case forward:
+			edges, err = addRoad(graph, id, start, end, speed, points, backward)
+		case backward:
+			edges, err = addRoad(graph, id, end, start, speed, reversePoints(points), false)
+		}
+		if err != nil {
+			return fmt.Errorf("feature %d: %w", i, err)
+		}
+		setAttributes(edges, attributes)
+	}
+	return nil
+}
+
+func reversePoints(points []pkg.Point) []pkg.Point {
+	reversed := make([]pkg.Point, len(points))
+	for i, point := range points {
+		reversed[len(points)-1-i] = point
+	}
+	return reversed
+}
+
+func parseTimeValue(value interface{}) (time.Time, error) {
+	switch value := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t, nil
+		}
+		return time.Parse(TimeFormat, value)
+	case float64:
+		if math.Abs(value) >= 1e11 {
+			return time.UnixMilli(int64(value)).UTC(), nil
+		}
+		seconds, fraction := math.Modf(value)
+		return time.Unix(int64(seconds), int64(fraction*1e9)).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("unsupported time value %v", value)
+}
+
+func ParseGeoJSONTraces(path string, properties GeoJSONTraceProperties) ([][]GPSPoint, error) {
+	collection, err := ReadGeoJSON(path)
+	if err != nil {
+		return nil, err
+	}
+
+	traces, points := make([][]GPSPoint, 0), make([]GPSPoint, 0)
+	for i, feature := range collection.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+
+		switch feature.Geometry.Type {
+		case "LineString":
+			locations, err := feature.Geometry.LineString()
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+			timestamps, ok := feature.Properties[properties.Timestamps].([]interface{})
+			if !ok || len(timestamps) != len(locations) {
+				return nil, fmt.Errorf("feature %d: %w: %s must list one time per position", i, ErrMissingProperty, properties.Timestamps)
+			}
+
+			trace := make([]GPSPoint, 0, len(locations))
+			for j, location := range locations {
+				dateTime, err := parseTimeValue(timestamps[j])
+				if err != nil {
+					return nil, fmt.Errorf("feature %d: %w", i, err)
+				}
+				trace = append(trace, GPSPoint{Location: location, Time: dateTime})
+			}
+			SortByTime(trace)
+			traces = append(traces, trace)
+		case "Point":
+			location, err := feature.Geometry.Point()
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+			value, ok := feature.Properties[properties.Time]
+			if !ok {
+				return nil, fmt.Errorf("feature %d: %w: %s", i, ErrMissingProperty, properties.Time)
+			}
+			dateTime, err := parseTimeValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("feature %d: %w", i, err)
+			}
+			points = append(points, GPSPoint{Location: location, Time: dateTime})
+		}
+	}
+
+	if len(points) > 0 {
+		SortByTime(points)
+		traces = append(traces, points)
+	}
+	return traces, nil
+}
@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGeoJSONPropertiesRename(t *testing.T) {
+	network := DefaultGeoJSONNetworkProperties
+	if err := network.Rename(map[string]string{"id": "osm_id", "speed": "maxspeed"}); err != nil {
+		t.Fatal(err)
+	}
+	if network.ID != "osm_id" || network.Speed != "maxspeed" || network.Oneway != DefaultGeoJSONNetworkProperties.Oneway {
+		t.Errorf("renamed network properties %+v", network)
+	}
+	if err := network.Rename(map[string]string{"timestamps": "times"}); !errors.Is(err, ErrUnknownProperty) {
+		t.Errorf("renaming a trace field on the network: %v, want %v", err, ErrUnknownProperty)
+	}
+
+	trace := DefaultGeoJSONTraceProperties
+	if err := trace.Rename(map[string]string{"timestamps": "times"}); err != nil {
+		t.Fatal(err)
+	}
+	if trace.Timestamps != "times" || trace.Time != DefaultGeoJSONTraceProperties.Time {
+		t.Errorf("renamed trace properties %+v", trace)
+	}
+}
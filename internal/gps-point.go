@@ -12,8 +12,13 @@ const (
 )
 
 type GPSPoint struct {
-	Location pkg.Point
-	Time     time.Time
+	Location   pkg.Point
+	Time       time.Time
+	Elevation  *float64 `json:",omitempty"`
+	HDOP       *float64 `json:",omitempty"`
+	Speed      *float64 `json:",omitempty"`
+	Course     *float64 `json:",omitempty"`
+	FixQuality *int     `json:",omitempty"`
 }
 
 func (p *GPSPoint) Distance(other GPSPoint) float64 {
@@ -24,17 +29,45 @@ func (p *GPSPoint) TimeDifference(other GPSPoint) float64 {
 	return p.Time.Sub(other.Time).Seconds()
 }
 
-func MapMatch(graph *pkg.Graph, points []GPSPoint) (match []*pkg.Edge, err error) {
-	match, err = BestMatch(graph, points)
+// MapMatch matches a trace after dropping nearby points and returns the route
+// with, for every input point, the position of its matched edge in the route
+// or -1 when it was left unmatched. Dropped points share the position of the
+// point they were close to.
+//
+// Overrides are evaluated at the time of each transition, so the graph clock
+// moves while matching and is restored afterwards; callers sharing a graph
+// must not match concurrently.
+func MapMatch(graph *pkg.Graph, points []GPSPoint, options MatchOptions) ([]*pkg.Edge, []int, error) {
+	defer graph.SetClock(graph.Clock())
+	trace, owners := removeNearbyPoints(points)
+	match, matched, err := BestMatch(graph, trace, options)
+	if err != nil {
+		return nil, nil, err
+	}
 	slices.Reverse(match)
-	return
+
+	positions := make([]int, len(points))
+	for i, owner := range owners {
+		positions[i] = -1
+		if position := matched[owner]; position >= 0 {
+			positions[i] = len(match) - 1 - position
+		}
+	}
+	return match, positions, nil
+}
+
+func RemoveNearbyPoints(points []GPSPoint) []GPSPoint {
+	result, _ := removeNearbyPoints(points)
+	return result
 }
 
-func RemoveNearbyPoints(points []GPSPoint) (result []GPSPoint) {
+func removeNearbyPoints(points []GPSPoint) (result []GPSPoint, owners []int) {
+	owners = make([]int, len(points))
 	for i := 0; i < len(points); i++ {
 		if i == 0 || points[i].Distance(points[i-1]) >= MaxNearby {
 			result = append(result, points[i])
 		}
+		owners[i] = len(result) - 1
 	}
 	return
 }
@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+var (
+	ErrMissingTime = errors.New("track point has no time")
+)
+
+type gpxFile struct {
+	Tracks []gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Latitude   float64       `xml:"lat,attr"`
+	Longitude  float64       `xml:"lon,attr"`
+	Elevation  *float64      `xml:"ele"`
+	Time       string        `xml:"time"`
+	HDOP       *float64      `xml:"hdop"`
+	Speed      *float64      `xml:"speed"`
+	Course     *float64      `xml:"course"`
+	Extensions gpxExtensions `xml:"extensions"`
+}
+
+type gpxExtensions struct {
+	Speed          *float64 `xml:"speed"`
+	Course         *float64 `xml:"course"`
+	TrackPointData struct {
+		Speed  *float64 `xml:"speed"`
+		Course *float64 `xml:"course"`
+	} `xml:"TrackPointExtension"`
+}
+
+func firstOf(values ...*float64) *float64 {
+	for _, value := range values {
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
+
+func (p *gpxPoint) toGPSPoint() (GPSPoint, error) {
+	if strings.TrimSpace(p.Time) == "" {
+		return GPSPoint{}, ErrMissingTime
+	}
+	dateTime, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(p.Time))
+	if err != nil {
+		return GPSPoint{}, err
+	}
+
+	return GPSPoint{
+		Location:  pkg.Point{Longitude: p.Longitude, Latitude: p.Latitude},
+		Time:      dateTime,
+		Elevation: p.Elevation,
+		HDOP:      p.HDOP,
+		Speed:     firstOf(p.Speed, p.Extensions.Speed, p.Extensions.TrackPointData.Speed),
+		Course:    firstOf(p.Course, p.Extensions.Course, p.Extensions.TrackPointData.Course),
+	}, nil
+}
+
+// ParseGPX returns one trace per track segment. Points without a time cannot
+// be matched and are skipped, as are segments left empty.
+func ParseGPX(path string) ([][]GPSPoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var gpx gpxFile
+	if err := xml.NewDecoder(file).Decode(&gpx); err != nil {
+		return nil, err
+	}
+
+	traces := make([][]GPSPoint, 0)
+	for _, track := range gpx.Tracks {
+		for _, segment := range track.Segments {
+			points := make([]GPSPoint, 0, len(segment.Points))
+			for _, point := range segment.Points {
+				gpsPoint, err := point.toGPSPoint()
+				if errors.Is(err, ErrMissingTime) {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				points = append(points, gpsPoint)
+			}
+			if len(points) == 0 {
+				continue
+			}
+			SortByTime(points)
+			traces = append(traces, points)
+		}
+	}
+	return traces, nil
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeGPX(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trace.gpx")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseGPXSkipsPointsWithoutTime(t *testing.T) {
+	traces, err := ParseGPX(writeGPX(t, `<?xml version="1.0"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <trkseg>
+      <trkpt lat="50.001" lon="10.001"><time>2024-01-01T08:00:05Z</time><speed>12.5</speed></trkpt>
+      <trkpt lat="50.002" lon="10.002"></trkpt>
+      <trkpt lat="50.000" lon="10.000"><ele>100</ele><time>2024-01-01T08:00:00Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="50.003" lon="10.003"><time> </time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="50.004" lon="10.004"><time>2024-01-01T09:00:00Z</time><extensions><TrackPointExtension><course>90</course></TrackPointExtension></extensions></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(traces) != 2 || len(traces[0]) != 2 || len(traces[1]) != 1 {
+		t.Fatalf("traces %v, want the timed points of the first and last segments", traces)
+	}
+	first := traces[0][0]
+	if first.Location.Latitude != 50 || first.Elevation == nil || *first.Elevation != 100 {
+		t.Errorf("first point %+v, want the earliest one with its elevation", first)
+	}
+	if speed := traces[0][1].Speed; speed == nil || *speed != 12.5 {
+		t.Errorf("second point speed %v, want 12.5", speed)
+	}
+	if course := traces[1][0].Course; course == nil || *course != 90 {
+		t.Errorf("extension course %v, want 90", course)
+	}
+}
+
+func TestParseGPXRejectsMalformedTimes(t *testing.T) {
+	_, err := ParseGPX(writeGPX(t, `<gpx><trk><trkseg><trkpt lat="50" lon="10"><time>yesterday</time></trkpt></trkseg></trk></gpx>`))
+	if err == nil {
+		t.Errorf("parsed a point timed yesterday")
+	}
+}
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"errors"
+	"fmt"
 	"slices"
 	"strconv"
 	"strings"
@@ -8,25 +10,14 @@ import (
 	"github.com/ArshiaDadras/Ariadne/pkg"
 )
 
-func parsePoints(pointStr string) (points []pkg.Point, err error) {
-	for _, point := range strings.Split(pointStr[11:len(pointStr)-1], ", ") {
-		coordinates := strings.Split(point, " ")
-		longitude, err := strconv.ParseFloat(coordinates[0], 64)
-		if err != nil {
-			return nil, err
-		}
-		latitude, err := strconv.ParseFloat(coordinates[1], 64)
-		if err != nil {
-			return nil, err
-		}
+const (
+	IndexSegment = "segment"
+	IndexRTree   = "rtree"
+)
 
-		points = append(points, pkg.Point{
-			Longitude: longitude,
-			Latitude:  latitude,
-		})
-	}
-	return
-}
+var (
+	ErrUnknownIndex = errors.New("unknown spatial index")
+)
 
 func getOrCreateNode(graph *pkg.Graph, nodeID string, point pkg.Point, mp map[pkg.Point]string) (node *pkg.Node, err error) {
 	if mp != nil {
@@ -47,24 +38,97 @@ func getOrCreateNode(graph *pkg.Graph, nodeID string, point pkg.Point, mp map[pk
 	return
 }
 
-func parseRow(row []string, graph *pkg.Graph, mp map[pkg.Point]string) (start, end *pkg.Node, speed float64, points []pkg.Point, err error) {
-	points, err = parsePoints(row[6])
+type networkColumns struct {
+	ID            int
+	Start         int
+	End           int
+	Bidirectional int
+	Speed         int
+	Geometry      int
+	Class         int
+	Name          int
+	Lanes         int
+	Access        int
+	Surface       int
+}
+
+func resolveNetworkColumns(header []string, schema NetworkSchema) (networkColumns, error) {
+	if err := requireColumns(map[string]string{
+		"id":            schema.ID,
+		"start":         schema.Start,
+		"end":           schema.End,
+		"bidirectional": schema.Bidirectional,
+		"speed":         schema.Speed,
+		"geometry":      schema.Geometry,
+	}); err != nil {
+		return networkColumns{}, err
+	}
+	columns, err := resolveColumns(header, schema.ID, schema.Start, schema.End, schema.Bidirectional, schema.Speed, schema.Geometry,
+		schema.Class, schema.Name, schema.Lanes, schema.Access, schema.Surface)
 	if err != nil {
+		return networkColumns{}, err
+	}
+	return networkColumns{
+		ID:            columns[0],
+		Start:         columns[1],
+		End:           columns[2],
+		Bidirectional: columns[3],
+		Speed:         columns[4],
+		Geometry:      columns[5],
+		Class:         columns[6],
+		Name:          columns[7],
+		Lanes:         columns[8],
+		Access:        columns[9],
+		Surface:       columns[10],
+	}, nil
+}
+
+func parseBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "y":
+		return true
+	}
+	return false
+}
+
+func parseRow(row []string, columns networkColumns, graph *pkg.Graph, mp map[pkg.Point]string) (start, end *pkg.Node, speed float64, points []pkg.Point, err error) {
+	geometry, err := cell(row, columns.Geometry)
+	if err != nil {
+		return
+	}
+	points, err = ParseWKTLineString(geometry)
+	if err != nil {
+		err = columnError(columns.Geometry, err)
 		return
 	}
 
-	start, err = getOrCreateNode(graph, row[1], points[0], mp)
+	startID, err := cell(row, columns.Start)
 	if err != nil {
 		return
 	}
+	start, err = getOrCreateNode(graph, startID, points[0], mp)
+	if err != nil {
+		err = columnError(columns.Start, err)
+		return
+	}
 
-	end, err = getOrCreateNode(graph, row[2], points[len(points)-1], mp)
+	endID, err := cell(row, columns.End)
+	if err != nil {
+		return
+	}
+	end, err = getOrCreateNode(graph, endID, points[len(points)-1], mp)
 	if err != nil {
+		err = columnError(columns.End, err)
 		return
 	}
 
-	speed, err = strconv.ParseFloat(row[4], 64)
+	value, err := cell(row, columns.Speed)
+	if err != nil {
+		return
+	}
+	speed, err = strconv.ParseFloat(value, 64)
 	if err != nil {
+		err = columnError(columns.Speed, err)
 		return
 	}
 	speed *= 1000.0 / 3600.0
@@ -72,8 +136,89 @@ func parseRow(row []string, graph *pkg.Graph, mp map[pkg.Point]string) (start, e
 	return
 }
 
+func parseLanes(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	first, _, _ := strings.Cut(value, ";")
+	return strconv.Atoi(strings.TrimSpace(first))
+}
+
+func parseAttributes(row []string, columns networkColumns) (*pkg.Attributes, error) {
+	lanes, err := parseLanes(optionalCell(row, columns.Lanes))
+	if err != nil {
+		return nil, columnError(columns.Lanes, err)
+	}
+
+	attributes := &pkg.Attributes{
+		Class:   optionalCell(row, columns.Class),
+		Name:    optionalCell(row, columns.Name),
+		Lanes:   lanes,
+		Access:  optionalCell(row, columns.Access),
+		Surface: optionalCell(row, columns.Surface),
+	}
+	if attributes.Empty() {
+		return nil, nil
+	}
+	return attributes, nil
+}
+
+func setAttributes(edges []*pkg.Edge, attributes *pkg.Attributes) {
+	for _, edge := range edges {
+		edge.Attributes = attributes
+	}
+}
+
+// addRoad returns the edges of the road, including those recorded as clashes,
+// so that callers can annotate them either way.
+func addRoad(graph *pkg.Graph, id string, start, end *pkg.Node, speed float64, points []pkg.Point, bidirectional bool) ([]*pkg.Edge, error) {
+	edges := []*pkg.Edge{pkg.NewEdge(id, start, end, speed, points)}
+	if bidirectional {
+		edges = append(edges, pkg.NewEdge(id+pkg.ReverseSuffix, end, start, speed, reversePoints(points)))
+	}
+
+	for _, edge := range edges {
+		if err := graph.InsertEdge(edge); err != nil {
+			return nil, err
+		}
+	}
+	return edges, nil
+}
+
+// rejectRow records a row whose geometry cannot form an edge, so that
+// validation reports it instead of stopping at the first one.
+func rejectRow(graph *pkg.Graph, row []string, columns networkColumns) {
+	parts, _ := ParseWKT(optionalCell(row, columns.Geometry))
+	edge := &pkg.Edge{
+		ID:    optionalCell(row, columns.ID),
+		Start: optionalCell(row, columns.Start),
+		End:   optionalCell(row, columns.End),
+		Poly:  slices.Concat(parts...),
+	}
+	for i := 1; i < len(edge.Poly); i++ {
+		edge.Length += edge.Poly[i].Distance(edge.Poly[i-1])
+	}
+	if speed, err := strconv.ParseFloat(optionalCell(row, columns.Speed), 64); err == nil {
+		edge.Speed = speed * 1000.0 / 3600.0
+	}
+	graph.Rejected = append(graph.Rejected, edge)
+}
+
 func BuildRoadNetwork(graph *pkg.Graph, path string, removeDuplicates bool) error {
-	data, err := ParseCSV(path)
+	return BuildRoadNetworkWithSchema(graph, path, FormatTSV, DefaultSchema, removeDuplicates)
+}
+
+func BuildRoadNetworkWithSchema(graph *pkg.Graph, path, format string, schema Schema, removeDuplicates bool) error {
+	delimiter, err := schema.delimiter(format)
+	if err != nil {
+		return err
+	}
+	header, data, err := ReadTable(path, delimiter, schema.HasHeader())
+	if err != nil {
+		return err
+	}
+
+	columns, err := resolveNetworkColumns(header, schema.Network)
 	if err != nil {
 		return err
 	}
@@ -83,33 +228,87 @@ func BuildRoadNetwork(graph *pkg.Graph, path string, removeDuplicates bool) erro
 		mp = make(map[pkg.Point]string)
 	}
 
-	for _, row := range data {
-		start, end, speed, points, err := parseRow(row, graph, mp)
+	firstRow := 1
+	if schema.HasHeader() {
+		firstRow = 2
+	}
+
+	for i, row := range data {
+		start, end, speed, points, err := parseRow(row, columns, graph, mp)
+		if graph.RecordClashes && (errors.Is(err, ErrInvalidWKT) || errors.Is(err, ErrDisjointGeometry)) {
+			rejectRow(graph, row, columns)
+			continue
+		} else if err != nil {
+			return withRow(err, i+firstRow)
+		}
+
+		id, err := cell(row, columns.ID)
 		if err != nil {
-			return err
+			return withRow(err, i+firstRow)
+		}
+		bidirectional, err := cell(row, columns.Bidirectional)
+		if err != nil {
+			return withRow(err, i+firstRow)
 		}
 
-		_, err = graph.AddEdge(row[0], start, end, speed, points)
+		attributes, err := parseAttributes(row, columns)
 		if err != nil {
-			return err
+			return withRow(err, i+firstRow)
 		}
 
-		if row[3] == "1" {
-			slices.Reverse(points)
-			_, err = graph.AddEdge(row[0]+"_reverse", end, start, speed, points)
-			if err != nil {
-				return err
-			}
+		edges, err := addRoad(graph, id, start, end, speed, points, parseBool(bidirectional))
+		if err != nil {
+			return withRow(columnError(columns.ID, err), i+firstRow)
 		}
+		setAttributes(edges, attributes)
 	}
 
 	return nil
 }
 
+type NetworkOptions struct {
+	Format           string
+	RemoveDuplicates bool
+	RecordClashes    bool
+	Profile          string
+	Schema           Schema
+	Properties       *GeoJSONNetworkProperties
+}
+
+func BuildNetwork(graph *pkg.Graph, path string, options NetworkOptions) error {
+	graph.RecordClashes = options.RecordClashes
+	switch options.Format {
+	case FormatTSV, FormatCSV:
+		return BuildRoadNetworkWithSchema(graph, path, options.Format, options.Schema, options.RemoveDuplicates)
+	case FormatGeoJSON:
+		properties := DefaultGeoJSONNetworkProperties
+		if options.Properties != nil {
+			properties = *options.Properties
+		}
+		return BuildGeoJSONNetwork(graph, path, properties, options.RemoveDuplicates)
+	case FormatOSM:
+		profile, err := GetOSMProfile(options.Profile)
+		if err != nil {
+			return err
+		}
+		return BuildOSMNetwork(graph, path, profile)
+	case FormatPBF:
+		profile, err := GetOSMProfile(options.Profile)
+		if err != nil {
+			return err
+		}
+		return BuildOSMPBFNetwork(graph, path, profile)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, options.Format)
+}
+
 func Preprocess(graph *pkg.Graph) {
 	maxLength := 2 * MaxCandidateDistance
 	segmentNodes := make([]*pkg.SegmentNode, 0)
 	for _, edge := range graph.Edges {
+		if edge.Isolated {
+			continue
+		}
 		for i := 1; i < len(edge.Poly); i++ {
 			for start, end := edge.Poly[i-1], edge.Poly[i]; start.Distance(end) > maxLength; {
 				start = start.MoveTowards(end, maxLength)
@@ -128,5 +327,39 @@ func Preprocess(graph *pkg.Graph) {
 		}
 	}
 
-	graph.Seg = pkg.NewSegment2D(segmentNodes)
+	seg := pkg.NewSegment2D(segmentNodes)
+	seg.Padding = maxLength / 2
+	graph.Index = seg
+}
+
+func PreprocessRTree(graph *pkg.Graph) {
+	edges := make([]*pkg.Edge, 0, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		if !edge.Isolated {
+			edges = append(edges, edge)
+		}
+	}
+	graph.Index = pkg.NewRTree(edges)
+}
+
+func IndexKind(index pkg.SpatialIndex) string {
+	switch index.(type) {
+	case *pkg.Segment2D:
+		return IndexSegment
+	case *pkg.RTree:
+		return IndexRTree
+	}
+	return ""
+}
+
+func PreprocessIndex(graph *pkg.Graph, index string) error {
+	switch index {
+	case "", IndexSegment:
+		Preprocess(graph)
+	case IndexRTree:
+		PreprocessRTree(graph)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownIndex, index)
+	}
+	return nil
 }
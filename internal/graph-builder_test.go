@@ -0,0 +1,339 @@
+package internal
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+const (
+	benchmarkGridSize    = 100
+	benchmarkGridSpacing = 0.001
+	distanceTolerance    = 1e-6
+)
+
+func queryPoints(count int) []pkg.Point {
+	random, extent := rand.New(rand.NewSource(1)), float64(benchmarkGridSize-1)*benchmarkGridSpacing
+	points := make([]pkg.Point, count)
+	for i := range points {
+		points[i] = pkg.Point{Longitude: random.Float64() * extent, Latitude: random.Float64() * extent}
+	}
+	return points
+}
+
+var benchmarkIndexes = []string{IndexSegment, IndexRTree}
+
+func TestSpatialIndexesAgree(t *testing.T) {
+	graph := pkg.NewGridGraph(20, benchmarkGridSpacing)
+	results := make(map[string][][]pkg.Candidate)
+	for _, index := range benchmarkIndexes {
+		if err := PreprocessIndex(graph, index); err != nil {
+			t.Fatal(err)
+		}
+		for _, point := range queryPoints(200) {
+			results[index] = append(results[index], graph.Index.Nearest(point, MaxCandidates))
+		}
+	}
+
+	for i, segment := range results[IndexSegment] {
+		rtree := results[IndexRTree][i]
+		if len(segment) != len(rtree) {
+			t.Fatalf("query %d: segment found %d candidates, rtree %d", i, len(segment), len(rtree))
+		}
+		if len(segment) == 0 {
+			continue
+		}
+		for j := range segment {
+			if math.Abs(segment[j].Distance-rtree[j].Distance) > distanceTolerance {
+				t.Errorf("query %d, candidate %d: segment %s at %.3fm, rtree %s at %.3fm", i, j, segment[j].Edge.ID, segment[j].Distance, rtree[j].Edge.ID, rtree[j].Distance)
+			}
+		}
+		// The order of tied candidates is arbitrary, and a tie at the last
+		// distance may pick different edges; any other edge must match.
+		last := segment[len(segment)-1].Distance
+		distances := make(map[*pkg.Edge]float64)
+		for _, candidate := range segment {
+			distances[candidate.Edge] = candidate.Distance
+		}
+		for _, candidate := range rtree {
+			distance, ok := distances[candidate.Edge]
+			if ok && math.Abs(distance-candidate.Distance) > distanceTolerance || !ok && math.Abs(candidate.Distance-last) > distanceTolerance {
+				t.Errorf("query %d: rtree found %s at %.3fm, segment at %.3fm (found %v)", i, candidate.Edge.ID, candidate.Distance, distance, ok)
+			}
+		}
+	}
+}
+
+func indexSize(index pkg.SpatialIndex) int {
+	switch index := index.(type) {
+	case *pkg.Segment2D:
+		return index.Size
+	case *pkg.RTree:
+		return index.Size
+	}
+	return -1
+}
+
+// checkIndex compares radius and nearest queries against a scan of the graph.
+func checkIndex(t *testing.T, step string, graph *pkg.Graph) {
+	t.Helper()
+	if size := indexSize(graph.Index); size != len(graph.Edges) {
+		t.Errorf("%s: index holds %d edges, graph has %d", step, size, len(graph.Edges))
+	}
+	for _, point := range queryPoints(50) {
+		want := make(map[*pkg.Edge]bool)
+		for _, edge := range graph.Edges {
+			if point.DistanceToEdge(edge) <= 150 {
+				want[edge] = true
+			}
+		}
+		found := graph.Index.WithinRadius(point, 150)
+		if len(found) != len(want) {
+			t.Errorf("%s: found %d edges within 150m of %v, want %d", step, len(found), point, len(want))
+		}
+		for _, candidate := range found {
+			if !want[candidate.Edge] {
+				t.Errorf("%s: found stale edge %s", step, candidate.Edge.ID)
+			}
+		}
+		if nearest := graph.Index.Nearest(point, 1); len(nearest) != min(len(graph.Edges), 1) || len(nearest) == 1 && graph.Edges[nearest[0].Edge.ID] != nearest[0].Edge {
+			t.Errorf("%s: nearest to %v is %v", step, point, nearest)
+		}
+	}
+}
+
+func sortedEdges(graph *pkg.Graph) []*pkg.Edge {
+	edges := make([]*pkg.Edge, 0, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		edges = append(edges, edge)
+	}
+	slices.SortFunc(edges, func(a, b *pkg.Edge) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return edges
+}
+
+func TestSpatialIndexesFollowEdgeChanges(t *testing.T) {
+	for _, index := range benchmarkIndexes {
+		t.Run(index, func(t *testing.T) {
+			graph := pkg.NewGridGraph(10, 0.01)
+			if err := PreprocessIndex(graph, index); err != nil {
+				t.Fatal(err)
+			}
+			checkIndex(t, "built", graph)
+
+			removed := make([]*pkg.Edge, 0)
+			for i, edge := range sortedEdges(graph) {
+				if i%3 != 0 {
+					removed = append(removed, edge)
+					if err := graph.RemoveEdge(edge.ID); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			checkIndex(t, "removed", graph)
+
+			for _, edge := range graph.Edges {
+				graph.Index.Insert(edge)
+			}
+			checkIndex(t, "inserted twice", graph)
+
+			for _, edge := range removed {
+				if err := graph.InsertEdge(edge); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkIndex(t, "restored", graph)
+
+			for _, edge := range sortedEdges(graph) {
+				if err := graph.RemoveEdge(edge.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkIndex(t, "emptied", graph)
+			if rtree, ok := graph.Index.(*pkg.RTree); ok && (len(rtree.Root.Children) > 0 || len(rtree.Root.Entries) > 0) {
+				t.Errorf("emptied rtree keeps root %+v", rtree.Root)
+			}
+
+			a, b := graph.Nodes["0,0"], graph.Nodes["9,9"]
+			if _, err := graph.AddEdge("diagonal", a, b, 10, []pkg.Point{a.Position, b.Position}); err != nil {
+				t.Fatal(err)
+			}
+			checkIndex(t, "refilled", graph)
+		})
+	}
+}
+
+func BenchmarkSpatialIndexBuild(b *testing.B) {
+	graph := pkg.NewGridGraph(benchmarkGridSize, benchmarkGridSpacing)
+	for _, index := range benchmarkIndexes {
+		b.Run(index, func(b *testing.B) {
+			var before, after runtime.MemStats
+			graph.Index = nil
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			if err := PreprocessIndex(graph, index); err != nil {
+				b.Fatal(err)
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				PreprocessIndex(graph, index)
+			}
+			b.ReportMetric(float64(after.HeapAlloc)-float64(before.HeapAlloc), "retained-B")
+		})
+	}
+}
+
+func BenchmarkSpatialIndexNearest(b *testing.B) {
+	graph, points := pkg.NewGridGraph(benchmarkGridSize, benchmarkGridSpacing), queryPoints(1024)
+	for _, index := range benchmarkIndexes {
+		b.Run(index, func(b *testing.B) {
+			if err := PreprocessIndex(graph, index); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				graph.Index.Nearest(points[i%len(points)], MaxCandidates)
+			}
+		})
+	}
+}
+
+func BenchmarkSpatialIndexWithinRadius(b *testing.B) {
+	graph, points := pkg.NewGridGraph(benchmarkGridSize, benchmarkGridSpacing), queryPoints(1024)
+	for _, index := range benchmarkIndexes {
+		b.Run(index, func(b *testing.B) {
+			if err := PreprocessIndex(graph, index); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				graph.Index.WithinRadius(points[i%len(points)], MaxCandidateDistance)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeNetwork(t *testing.T, rows ...string) string {
+	t.Helper()
+	return writeFile(t, "network.tsv", "id\tstart\tend\tbidirectional\tspeed\tname\tgeometry\n"+strings.Join(rows, "\n")+"\n")
+}
+
+func TestBuildNetworkRecordsBadGeometryForValidation(t *testing.T) {
+	path := writeNetwork(t,
+		"good\ta\tb\t1\t36\tMain\tLINESTRING (0 0, 0.001 0)",
+		"malformed\tb\tc\t0\t36\tMain\tLINESTRING (0.001 0, 0.002",
+		"single\tc\td\t0\t36\tMain\tLINESTRING (0.002 0)",
+		"zero\td\te\t0\t36\tMain\tLINESTRING (0.003 0, 0.003 0)",
+		"good\tb\ta\t0\t36\tMain\tLINESTRING (0.001 0, 0 0)",
+	)
+
+	strict := pkg.NewGraph()
+	var parseError *ParseError
+	if err := BuildNetwork(strict, path, NetworkOptions{Format: FormatTSV, Schema: DefaultSchema}); !errors.As(err, &parseError) || parseError.Row != 3 || !errors.Is(err, ErrInvalidWKT) {
+		t.Fatalf("strict load: %v, want invalid WKT on row 3", err)
+	}
+
+	graph := pkg.NewGraph()
+	if err := BuildNetwork(graph, path, NetworkOptions{Format: FormatTSV, Schema: DefaultSchema, RecordClashes: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Edges) != 2 || graph.Edges["good"] == nil || graph.Edges["good"+pkg.ReverseSuffix] == nil {
+		t.Errorf("loaded edges %v, want good and its reverse", sortedEdges(graph))
+	}
+
+	report := graph.Validate()
+	for kind, want := range map[string][]string{
+		pkg.IssueInvalidGeometry: {"malformed", "single"},
+		pkg.IssueZeroLength:      {"zero"},
+		pkg.IssueDuplicateID:     {"good"},
+	} {
+		issue := report.Issue(kind)
+		if issue == nil || !slices.Equal(issue.Examples, want) {
+			t.Errorf("%s issue %+v, want %v", kind, issue, want)
+		}
+	}
+	if zero := report.Issue(pkg.IssueZeroLength); zero != nil && (zero.Edges[0].Start != "d" || zero.Edges[0].Speed != 10) {
+		t.Errorf("zero-length row recorded as %+v, want its start and speed", zero.Edges[0])
+	}
+}
+
+func TestNetworkFormatsCarryAttributes(t *testing.T) {
+	attributes := &pkg.Attributes{Class: "primary", Name: "Main", Lanes: 2, Access: "destination", Surface: "asphalt"}
+	osmAttributes := &pkg.Attributes{Class: "primary", Name: "Main", Lanes: 2, Access: "destination", Surface: "asphalt", Tags: map[string]string{"ref": "B1"}}
+	schema := Schema{Delimiter: ",", Network: NetworkSchema{
+		ID: "id", Start: "start", End: "end", Bidirectional: "bidirectional", Speed: "speed", Geometry: "geometry",
+		Class: "class", Name: "name", Lanes: "lanes", Access: "access", Surface: "surface",
+	}}
+	for _, test := range []struct {
+		name    string
+		path    string
+		options NetworkOptions
+		want    *pkg.Attributes
+	}{
+		{"csv", writeFile(t, "network.csv", "id,start,end,bidirectional,speed,class,name,lanes,access,surface,geometry\n"+
+			"main,a,b,1,36,primary,Main,2,destination,asphalt,\"LINESTRING (0 0, 0.001 0)\"\n"),
+			NetworkOptions{Format: FormatCSV, Schema: schema}, attributes},
+		{"geojson", writeFile(t, "network.geojson", `{"type": "FeatureCollection", "features": [{"type": "Feature",
+			"geometry": {"type": "LineString", "coordinates": [[0, 0], [0.001, 0]]},
+			"properties": {"id": "main", "speed": 36, "class": "primary", "name": "Main", "lanes": 2, "access": "destination", "surface": "asphalt"}}]}`),
+			NetworkOptions{Format: FormatGeoJSON}, attributes},
+		{"osm", writeFile(t, "network.osm", `<osm version="0.6">
+			<node id="1" lat="0" lon="0"/><node id="2" lat="0" lon="0.001"/>
+			<way id="10"><nd ref="1"/><nd ref="2"/>
+			<tag k="highway" v="primary"/><tag k="name" v="Main"/><tag k="lanes" v="2"/>
+			<tag k="access" v="destination"/><tag k="surface" v="asphalt"/><tag k="ref" v="B1"/></way></osm>`),
+			NetworkOptions{Format: FormatOSM, Profile: "car"}, osmAttributes},
+	} {
+		built := pkg.NewGraph()
+		if err := BuildNetwork(built, test.path, test.options); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		graphs := map[string]*pkg.Graph{test.name: built}
+		for _, name := range []string{"graph.json", "graph" + BinaryExtension} {
+			path := filepath.Join(t.TempDir(), name)
+			if err := SaveObjectWith(built, path, OutputOptionsFor(path, true)); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := LoadGraph(path)
+			if err != nil {
+				t.Fatalf("%s %s: %v", test.name, name, err)
+			}
+			graphs[test.name+" via "+name] = loaded
+		}
+
+		for name, graph := range graphs {
+			if len(graph.Edges) != 2 {
+				t.Errorf("%s: %d edges, want both directions of the road", name, len(graph.Edges))
+			}
+			for id, edge := range graph.Edges {
+				if !edge.Attributes.Equal(test.want) {
+					t.Errorf("%s: edge %s has attributes %+v, want %+v", name, id, edge.Attributes, test.want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+var (
+	ErrInvalidGraph = errors.New("invalid graph file")
+)
+
+func openInput(path string) (io.Reader, func() error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(file)
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		decompressor, err := gzip.NewReader(reader)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return decompressor, func() error { return errors.Join(decompressor.Close(), file.Close()) }, nil
+	}
+	return reader, file.Close, nil
+}
+
+func expectDelimiter(decoder *json.Decoder, delimiter json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delimiter {
+		return fmt.Errorf("%w: expected %v, got %v", ErrInvalidGraph, delimiter, token)
+	}
+	return nil
+}
+
+func decodeMembers(decoder *json.Decoder, visit func(string) error) error {
+	if err := expectDelimiter(decoder, '{'); err != nil {
+		return err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err := visit(token.(string)); err != nil {
+			return err
+		}
+	}
+	return expectDelimiter(decoder, '}')
+}
+
+func addLoadedNode(graph *pkg.Graph, node *pkg.Node) error {
+	if node == nil {
+		return fmt.Errorf("%w: empty node record", ErrInvalidGraph)
+	}
+	if _, err := graph.AddNode(node.ID, node.Position); err != nil {
+		return fmt.Errorf("node %s: %w", node.ID, err)
+	}
+	return nil
+}
+
+func addLoadedEdges(graph *pkg.Graph, edges []*pkg.Edge) error {
+	for _, edge := range edges {
+		if err := graph.InsertEdge(edge); err != nil {
+			return fmt.Errorf("edge %s: %w", edge.ID, err)
+		}
+	}
+	return nil
+}
+
+func readGraphJSON(reader io.Reader, graph *pkg.Graph) error {
+	edges := make([]*pkg.Edge, 0)
+	decoder := json.NewDecoder(reader)
+	err := decodeMembers(decoder, func(key string) error {
+		switch key {
+		case "nodes":
+			return decodeMembers(decoder, func(id string) error {
+				var node pkg.Node
+				if err := decoder.Decode(&node); err != nil {
+					return fmt.Errorf("node %s: %w", id, err)
+				}
+				return addLoadedNode(graph, &node)
+			})
+		case "edges":
+			return decodeMembers(decoder, func(id string) error {
+				var edge pkg.Edge
+				if err := decoder.Decode(&edge); err != nil {
+					return fmt.Errorf("edge %s: %w", id, err)
+				}
+				edges = append(edges, &edge)
+				return nil
+			})
+		}
+
+		var ignored json.RawMessage
+		return decoder.Decode(&ignored)
+	})
+	if err != nil {
+		return err
+	}
+	return addLoadedEdges(graph, edges)
+}
+
+func readGraphNDJSON(reader io.Reader, graph *pkg.Graph) error {
+	edges := make([]*pkg.Edge, 0)
+	decoder := json.NewDecoder(reader)
+	for line := 1; ; line++ {
+		var record graphRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		switch record.Type {
+		case "node":
+			if err := addLoadedNode(graph, record.Node); err != nil {
+				return fmt.Errorf("record %d: %w", line, err)
+			}
+		case "edge":
+			if record.Edge == nil {
+				return fmt.Errorf("record %d: %w: empty edge record", line, ErrInvalidGraph)
+			}
+			edges = append(edges, record.Edge)
+		default:
+			return fmt.Errorf("record %d: %w: unknown record type %q", line, ErrInvalidGraph, record.Type)
+		}
+	}
+	return addLoadedEdges(graph, edges)
+}
+
+func LoadGraph(path string) (*pkg.Graph, error) {
+	return LoadGraphWith(path, false, "")
+}
+
+// LoadGraphWith loads a graph and builds the requested spatial index for it;
+// binary graphs keep the R-tree stored with them.
+func LoadGraphWith(path string, recordClashes bool, index string) (*pkg.Graph, error) {
+	if isBinaryGraph(path) {
+		return LoadBinaryGraph(path)
+	}
+
+	reader, closeInput, err := openInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeInput()
+
+	graph := pkg.NewGraph()
+	graph.RecordClashes = recordClashes
+	if OutputOptionsFor(path, false).Format == FormatNDJSON {
+		err = readGraphNDJSON(reader, graph)
+	} else {
+		err = readGraphJSON(reader, graph)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := PreprocessIndex(graph, index); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+func loaderGraph(t *testing.T) *pkg.Graph {
+	t.Helper()
+	graph := pkg.NewGraph()
+	a, _ := graph.AddNode("a", pkg.Point{Longitude: 10, Latitude: 50})
+	b, _ := graph.AddNode("b", pkg.Point{Longitude: 10.002, Latitude: 50})
+	c, _ := graph.AddNode("c", pkg.Point{Longitude: 10.002, Latitude: 50.002})
+
+	profile, err := pkg.NewSpeedProfile(60, []float64{5, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, edge := range []*pkg.Edge{
+		pkg.NewEdge("ab", a, b, 10, []pkg.Point{a.Position, {Longitude: 10.001, Latitude: 50.0005}, b.Position}),
+		pkg.NewEdge("ab_parallel", a, b, 8, []pkg.Point{a.Position, b.Position}),
+		pkg.NewEdge("bc", b, c, 12, []pkg.Point{b.Position, c.Position}),
+		pkg.NewEdge("ca", c, a, 5, []pkg.Point{c.Position, a.Position}),
+	} {
+		if err := graph.InsertEdge(edge); err != nil {
+			t.Fatal(err)
+		}
+	}
+	graph.Edges["ab"].Attributes = &pkg.Attributes{Class: "primary", Name: "Main", Lanes: 2, Tags: map[string]string{"ref": "B1"}}
+	graph.Edges["ab"].Profile = profile
+	graph.Edges["ca"].Isolated = true
+	return graph
+}
+
+func TestSavedGraphLoadsBack(t *testing.T) {
+	original := loaderGraph(t)
+	for _, name := range []string{"graph.json", "graph.ndjson", "graph.json.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := SaveObjectWith(original, path, OutputOptionsFor(path, true)); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadGraph(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(loaded.Nodes) != len(original.Nodes) || len(loaded.Edges) != len(original.Edges) {
+			t.Fatalf("%s: %d nodes and %d edges, want %d and %d", name, len(loaded.Nodes), len(loaded.Edges), len(original.Nodes), len(original.Edges))
+		}
+		for id, edge := range original.Edges {
+			got := loaded.Edges[id]
+			if got == nil || got.Start != edge.Start || got.End != edge.End || got.Speed != edge.Speed || !slices.Equal(got.Poly, edge.Poly) || got.Isolated != edge.Isolated {
+				t.Errorf("%s: edge %s is %+v, want %+v", name, id, got, edge)
+				continue
+			}
+			if !got.Attributes.Equal(edge.Attributes) || !got.Profile.Equal(edge.Profile) {
+				t.Errorf("%s: edge %s has attributes %+v and profile %+v, want %+v and %+v", name, id, got.Attributes, got.Profile, edge.Attributes, edge.Profile)
+			}
+		}
+
+		a, b := loaded.Nodes["a"], loaded.Nodes["b"]
+		if got := len(a.OutEdges[b]); got != 2 || len(b.InEdges[a]) != 2 {
+			t.Errorf("%s: %d parallel edges from a to b, want 2", name, got)
+		}
+		if groups := loaded.ParallelEdges(); len(groups) != 1 {
+			t.Errorf("%s: %d parallel groups, want 1", name, len(groups))
+		}
+
+		if kind := IndexKind(loaded.Index); kind != IndexSegment {
+			t.Fatalf("%s: index %q, want %q", name, kind, IndexSegment)
+		}
+		for _, candidate := range loaded.Index.WithinRadius(pkg.Point{Longitude: 10.001, Latitude: 50.001}, 1000) {
+			if candidate.Edge.Isolated {
+				t.Errorf("%s: isolated edge %s is indexed", name, candidate.Edge.ID)
+			}
+		}
+		if distance, err := loaded.Distance("a", "c", 0, false); err != nil || distance <= 0 {
+			t.Errorf("%s: distance a→c %v (%v)", name, distance, err)
+		}
+	}
+}
+
+func TestLoadGraphWithBuildsTheRequestedIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.json")
+	if err := SaveObjectWith(loaderGraph(t), path, OutputOptionsFor(path, true)); err != nil {
+		t.Fatal(err)
+	}
+	for _, index := range []string{IndexSegment, IndexRTree} {
+		graph, err := LoadGraphWith(path, false, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind := IndexKind(graph.Index); kind != index {
+			t.Errorf("index %q, want %q", kind, index)
+		}
+	}
+	if _, err := LoadGraphWith(path, false, "quadtree"); err == nil {
+		t.Error("unknown index loaded without an error")
+	}
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"errors"
 	"math"
+	"slices"
 	"sort"
 
 	"github.com/ArshiaDadras/Ariadne/pkg"
@@ -18,50 +19,87 @@ const (
 	CandidateDistance    = 1.4142135624 * MaxCandidateDistance
 )
 
+// MatchOptions tunes the matcher: a U-turn costs UTurnPenalty metres unless
+// it backs up no more than JitterTolerance along the same edge, ClassPriors
+// weigh the emission of each road class and SpeedAware penalizes transitions
+// faster than SpeedTolerance times the edge speeds.
+type MatchOptions struct {
+	UTurnPenalty    float64
+	JitterTolerance float64
+	ClassPriors     map[string]float64
+	SpeedAware      bool
+	SpeedTolerance  float64
+	SpeedPenalty    float64
+}
+
+var DefaultMatchOptions = MatchOptions{
+	UTurnPenalty:    50.0,
+	JitterTolerance: 2 * Sigma,
+	SpeedTolerance:  1.5,
+	SpeedPenalty:    2.0,
+}
+
 var (
 	ErrNoPathFound = errors.New("no path found")
 )
 
-func BestMatch(graph *pkg.Graph, points []GPSPoint) ([]*pkg.Edge, error) {
+// BestMatch returns the matched route in reverse order together with, for
+// every point, the position in that route of the edge Viterbi chose for it or
+// -1 when the point had no candidates.
+func BestMatch(graph *pkg.Graph, points []GPSPoint, options MatchOptions) ([]*pkg.Edge, []int, error) {
+	indices, positions := make([]int, len(points)), make([]int, len(points))
+	for i := range points {
+		indices[i], positions[i] = i, -1
+	}
+	route, err := bestMatch(graph, options, slices.Clone(points), indices, positions)
+	return route, positions, err
+}
+
+func bestMatch(graph *pkg.Graph, options MatchOptions, points []GPSPoint, indices, positions []int) ([]*pkg.Edge, error) {
 	if len(points) == 0 {
 		return []*pkg.Edge{}, nil
 	}
 	dp, par := initializeDPAndPar(len(points))
-	initializeValues(graph, points[0], dp)
+	initializeValues(graph, options, points[0], dp)
 	filterCandidates(dp[0])
 
 	for i := 1; i < len(points); i++ {
 		if len(dp[i-1]) == 0 {
 			if i == 1 {
-				return BestMatch(graph, points[1:])
+				return bestMatch(graph, options, points[1:], indices[1:], positions)
 			} else {
 				dp, par = append(dp[:i-1], dp[i+1:]...), append(par[:i-1], par[i+1:]...)
-				points = append(points[:i-1], points[i+1:]...)
+				points, indices = append(points[:i-1], points[i+1:]...), append(indices[:i-1], indices[i+1:]...)
 				i--
 
 				if points[i].TimeDifference(points[i-1]) > MaxBreak {
-					return splitPath(graph, points, dp, par, i)
+					return splitPath(graph, options, points, indices, positions, dp, par, i)
 				}
 			}
 		}
 		normalizeValues(dp[i-1])
 
-		viterbi(graph, points, dp, par, i)
+		viterbi(graph, options, points, dp, par, i)
 		filterCandidates(dp[i])
 	}
 
-	return bestPath(graph, points, dp, par)
+	return bestPath(graph, points, indices, positions, dp, par)
 }
 
-func splitPath(graph *pkg.Graph, points []GPSPoint, dp []map[*pkg.Edge]float64, par []map[*pkg.Edge]*pkg.Edge, i int) ([]*pkg.Edge, error) {
-	path1, err := bestPath(graph, points[:i], dp[:i], par[:i])
+func splitPath(graph *pkg.Graph, options MatchOptions, points []GPSPoint, indices, positions []int, dp []map[*pkg.Edge]float64, par []map[*pkg.Edge]*pkg.Edge, i int) ([]*pkg.Edge, error) {
+	path1, err := bestPath(graph, points[:i], indices[:i], positions, dp[:i], par[:i])
 	if err != nil {
 		return nil, err
 	}
-	path2, err := BestMatch(graph, points[i:])
+	path2, err := bestMatch(graph, options, points[i:], indices[i:], positions)
 	if err != nil {
 		return nil, err
 	}
+	for _, index := range indices[:i] {
+		if positions[index] >= 0 {
+			positions[index] += len(path2)
+		}
+	}
 	return append(path2, path1...), nil
 }
 
@@ -75,12 +113,22 @@ func initializeDPAndPar(n int) ([]map[*pkg.Edge]float64, []map[*pkg.Edge]*pkg.Ed
 	return dp, par
 }
 
-func initializeValues(graph *pkg.Graph, initial GPSPoint, dp []map[*pkg.Edge]float64) {
-	for _, candidate := range graph.Seg.Get(initial.Location, CandidateDistance) {
-		dp[0][candidate] = EmmisionLogProbability(initial.Location.Distance(initial.Location.ClosestPointOnEdge(candidate)), Sigma)
+func initializeValues(graph *pkg.Graph, options MatchOptions, initial GPSPoint, dp []map[*pkg.Edge]float64) {
+	for _, candidate := range graph.Index.WithinRadius(initial.Location, CandidateDistance) {
+		if graph.DisabledAt(candidate.Edge, initial.Time) {
+			continue
+		}
+		dp[0][candidate.Edge] = EmmisionLogProbability(candidate.Distance, Sigma) + options.classLogPrior(candidate.Edge)
 	}
 }
 
+func (o MatchOptions) classLogPrior(edge *pkg.Edge) float64 {
+	if prior, ok := o.ClassPriors[edge.Class()]; ok && prior > 0 {
+		return math.Log(prior)
+	}
+	return 0
+}
+
 func normalizeValues(values map[*pkg.Edge]float64) {
 	best := math.Inf(-1)
 	for _, prob := range values {
@@ -93,7 +141,7 @@ func normalizeValues(values map[*pkg.Edge]float64) {
 	}
 }
 
-func bestPath(graph *pkg.Graph, points []GPSPoint, dp []map[*pkg.Edge]float64, par []map[*pkg.Edge]*pkg.Edge) ([]*pkg.Edge, error) {
+func bestPath(graph *pkg.Graph, points []GPSPoint, indices, positions []int, dp []map[*pkg.Edge]float64, par []map[*pkg.Edge]*pkg.Edge) ([]*pkg.Edge, error) {
 	best, edge := math.Inf(-1), (*pkg.Edge)(nil)
 	for candidate, prob := range dp[len(points)-1] {
 		if prob > best {
@@ -104,14 +152,20 @@ func bestPath(graph *pkg.Graph, points []GPSPoint, dp []map[*pkg.Edge]float64, p
 		return nil, ErrNoPathFound
 	}
 
-	result := make([]*pkg.Edge, 0)
+	result, pending := make([]*pkg.Edge, 0), make([]int, 0)
 	for i := len(points) - 1; i > 0; i-- {
+		pending = append(pending, indices[i])
 		if par[i][edge].ID != edge.ID {
-			path, err := graph.GetBestPath(graph.Nodes[edge.Start], graph.Nodes[par[i][edge].End], points[i].Location.Distance(points[i-1].Location)+MaxDiffDistance, true)
+			graph.SetClock(points[i-1].Time)
+			path, err := graph.Routing().Path(edge.Start, par[i][edge].End, points[i].Location.Distance(points[i-1].Location)+MaxDiffDistance, true)
 			if err != nil {
 				return nil, err
 			}
 
+			for _, index := range pending {
+				positions[index] = len(result)
+			}
+			pending = pending[:0]
 			result = append(result, edge)
 			result = append(result, path...)
 		}
@@ -121,42 +175,85 @@ func bestPath(graph *pkg.Graph, points []GPSPoint, dp []map[*pkg.Edge]float64, p
 	if len(result) == 0 || result[len(result)-1].ID != edge.ID {
 		result = append(result, edge)
 	}
+	for _, index := range append(pending, indices[0]) {
+		positions[index] = len(result) - 1
+	}
 	return result, nil
 }
 
-func viterbi(graph *pkg.Graph, points []GPSPoint, dp []map[*pkg.Edge]float64, par []map[*pkg.Edge]*pkg.Edge, i int) {
+// viterbi scores the candidates of point i, routing with the overrides active
+// when the previous point was recorded.
+func viterbi(graph *pkg.Graph, options MatchOptions, points []GPSPoint, dp []map[*pkg.Edge]float64, par []map[*pkg.Edge]*pkg.Edge, i int) {
+	graph.SetClock(points[i-1].Time)
 	d1 := points[i].Location.Distance(points[i-1].Location)
-	for _, candidate := range graph.Seg.Get(points[i].Location, CandidateDistance) {
+	for _, candidate := range graph.Index.WithinRadius(points[i].Location, CandidateDistance) {
+		if graph.DisabledAt(candidate.Edge, points[i].Time) {
+			continue
+		}
 		best, prv := math.Inf(-1), (*pkg.Edge)(nil)
 		for prev, prevProb := range dp[i-1] {
-			d2, err := roadDistance(graph, prev, candidate, points[i-1], points[i])
+			d2, err := options.roadDistance(graph, prev, candidate.Edge, points[i-1], points[i])
 			if err != nil {
 				continue
 			}
 
-			prob := prevProb + TransitionLogProbability(d1, d2, Beta)
+			prob := prevProb + TransitionLogProbability(d1, d2, Beta) + options.speedLogProbability(graph, prev, candidate.Edge, d2, points[i-1], points[i])
 			if prob > best {
 				best, prv = prob, prev
 			}
 		}
 
-		best += EmmisionLogProbability(points[i].Location.Distance(points[i].Location.ClosestPointOnEdge(candidate)), Sigma)
+		best += EmmisionLogProbability(candidate.Distance, Sigma) + options.classLogPrior(candidate.Edge)
 		if prv != nil {
-			dp[i][candidate] = best
-			par[i][candidate] = prv
+			dp[i][candidate.Edge] = best
+			par[i][candidate.Edge] = prv
 		}
 	}
 }
 
-func roadDistance(graph *pkg.Graph, prev, candidate *pkg.Edge, prevPoint, candidatePoint GPSPoint) (float64, error) {
+func (o MatchOptions) roadDistance(graph *pkg.Graph, prev, candidate *pkg.Edge, prevPoint, candidatePoint GPSPoint) (float64, error) {
 	if prev == candidate {
-		return prev.LengthFrom(prevPoint.Location) - candidate.LengthFrom(candidatePoint.Location), nil
+		return o.sameEdgeDistance(prev, prevPoint, candidatePoint), nil
 	}
-	d, err := graph.GetDistance(graph.Nodes[candidate.Start], graph.Nodes[prev.End], prevPoint.Location.Distance(candidatePoint.Location)+MaxDiffDistance, true)
-	if err != nil {
-		return 0, err
+	d, err := graph.Routing().Distance(candidate.Start, prev.End, prevPoint.Location.Distance(candidatePoint.Location)+MaxDiffDistance, true)
+	if err == nil {
+		d += prev.LengthFrom(prevPoint.Location) + candidate.LengthTo(candidatePoint.Location)
+	}
+	if prev.IsReverseOf(candidate) {
+		if u := o.twinUTurnDistance(prev, candidate, prevPoint, candidatePoint); err != nil || u < d {
+			return u, nil
+		}
+	}
+	return d, err
+}
+
+func (o MatchOptions) speedLogProbability(graph *pkg.Graph, prev, candidate *pkg.Edge, distance float64, prevPoint, candidatePoint GPSPoint) float64 {
+	elapsed := candidatePoint.Time.Sub(prevPoint.Time).Seconds()
+	if !o.SpeedAware || elapsed <= 0 {
+		return 0
 	}
-	return d + prev.LengthFrom(prevPoint.Location) + candidate.LengthTo(candidatePoint.Location), nil
+
+	limit := o.SpeedTolerance * math.Max(graph.SpeedAt(prev, prevPoint.Time), graph.SpeedAt(candidate, candidatePoint.Time))
+	if speed := distance / elapsed; limit > 0 && speed > limit {
+		return -o.SpeedPenalty * (speed/limit - 1)
+	}
+	return 0
+}
+
+func (o MatchOptions) sameEdgeDistance(edge *pkg.Edge, prevPoint, candidatePoint GPSPoint) float64 {
+	d := edge.LengthTo(candidatePoint.Location) - edge.LengthTo(prevPoint.Location)
+	if d >= 0 {
+		return d
+	} else if d >= -o.JitterTolerance {
+		return 0
+	}
+	return -d + o.UTurnPenalty
+}
+
+func (o MatchOptions) twinUTurnDistance(prev, candidate *pkg.Edge, prevPoint, candidatePoint GPSPoint) float64 {
+	from := prev.LengthTo(prevPoint.Location)
+	to := prev.Length - candidate.LengthTo(candidatePoint.Location)
+	return math.Abs(to-from) + o.UTurnPenalty
 }
 
 func filterCandidates(values map[*pkg.Edge]float64) {
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+// twinGraph is a dead-end street a–b drawn as the edge ab and its twin
+// ab_reverse, so the only way back along it is a U-turn.
+func twinGraph(t *testing.T) (*pkg.Graph, *pkg.Edge, *pkg.Edge) {
+	t.Helper()
+	graph := pkg.NewGraph()
+	a, err := graph.AddNode("a", pkg.Point{Longitude: 0, Latitude: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := graph.AddNode("b", pkg.Point{Longitude: 0.002, Latitude: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	forward, err := graph.AddEdge("ab", a, b, 10, []pkg.Point{a.Position, b.Position})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backward, err := graph.AddEdge("ab"+pkg.ReverseSuffix, b, a, 10, []pkg.Point{b.Position, a.Position})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return graph, forward, backward
+}
+
+func at(longitude float64) GPSPoint {
+	return GPSPoint{Location: pkg.Point{Longitude: longitude, Latitude: 0}}
+}
+
+func meters(degrees float64) float64 {
+	origin := pkg.Point{}
+	return origin.Distance(pkg.Point{Longitude: degrees})
+}
+
+func TestSameEdgeDistance(t *testing.T) {
+	_, edge, _ := twinGraph(t)
+	options := DefaultMatchOptions
+	jitter := options.JitterTolerance / meters(1)
+	for _, test := range []struct {
+		name       string
+		prev, next float64
+		want       float64
+	}{
+		{"forward", 0.0005, 0.0015, meters(0.001)},
+		{"standing still", 0.001, 0.001, 0},
+		{"jitter backwards", 0.001, 0.001 - jitter/2, 0},
+		{"at the jitter tolerance", 0.001, 0.001 - jitter*0.999, 0},
+		{"beyond the jitter tolerance", 0.001, 0.0005, meters(0.0005) + options.UTurnPenalty},
+	} {
+		if got := options.sameEdgeDistance(edge, at(test.prev), at(test.next)); math.Abs(got-test.want) > 1e-6 {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUTurnOptions(t *testing.T) {
+	graph, forward, backward := twinGraph(t)
+	options := MatchOptions{UTurnPenalty: 10}
+	prev, next := at(0.001), at(0.001-0.5/meters(1))
+	if got, want := options.sameEdgeDistance(forward, prev, next), 0.5+10.0; math.Abs(got-want) > 1e-6 {
+		t.Errorf("jitter without a tolerance: %v, want %v", got, want)
+	}
+	if got, err := options.roadDistance(graph, forward, backward, at(0.001), at(0.001)); err != nil || math.Abs(got-10) > 1e-6 {
+		t.Errorf("U-turn on the spot: %v (%v), want 10", got, err)
+	}
+}
+
+func TestTwinUTurnDistance(t *testing.T) {
+	_, forward, backward := twinGraph(t)
+	options := DefaultMatchOptions
+	for _, test := range []struct {
+		name       string
+		prev, next float64
+		want       float64
+	}{
+		{"turn back towards a", 0.0015, 0.001, meters(0.0005) + options.UTurnPenalty},
+		{"turn on the spot", 0.001, 0.001, options.UTurnPenalty},
+		{"jump ahead before turning", 0.001, 0.0015, meters(0.0005) + options.UTurnPenalty},
+	} {
+		if got := options.twinUTurnDistance(forward, backward, at(test.prev), at(test.next)); math.Abs(got-test.want) > 1e-6 {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRoadDistancePrefersTwinUTurn(t *testing.T) {
+	graph, forward, backward := twinGraph(t)
+	prev, next := at(0.0015), at(0.001)
+	options := DefaultMatchOptions
+
+	viaEnd := forward.LengthFrom(prev.Location) + backward.LengthTo(next.Location)
+	got, err := options.roadDistance(graph, forward, backward, prev, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := options.twinUTurnDistance(forward, backward, prev, next); math.Abs(got-want) > 1e-6 || want >= viaEnd {
+		t.Errorf("road distance %v, want the U-turn %v rather than turning at b %v", got, want, viaEnd)
+	}
+
+	if got, err := options.roadDistance(graph, forward, forward, prev, next); err != nil || math.Abs(got-(meters(0.0005)+options.UTurnPenalty)) > 1e-6 {
+		t.Errorf("backwards on the same edge: %v (%v), want %v", got, err, meters(0.0005)+options.UTurnPenalty)
+	}
+}
+
+func TestMapMatchEvaluatesOverridesPerTransition(t *testing.T) {
+	graph := pkg.NewGraph()
+	a, _ := graph.AddNode("a", pkg.Point{Longitude: 0, Latitude: 0})
+	b, _ := graph.AddNode("b", pkg.Point{Longitude: 0.002, Latitude: 0})
+	c, _ := graph.AddNode("c", pkg.Point{Longitude: 0.004, Latitude: 0})
+	for _, edge := range [][2]*pkg.Node{{a, b}, {b, c}} {
+		if _, err := graph.AddEdge(edge[0].ID+edge[1].ID, edge[0], edge[1], 10, []pkg.Point{edge[0].Position, edge[1].Position}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Preprocess(graph)
+
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := make([]GPSPoint, 0)
+	for _, longitude := range []float64{0.0005, 0.0015, 0.0025, 0.0035} {
+		points = append(points, GPSPoint{Location: pkg.Point{Longitude: longitude, Latitude: 0.00001}, Time: start.Add(time.Duration(len(points)) * 10 * time.Second)})
+	}
+	// bc reopens when the third point is recorded.
+	until := points[2].Time
+	if err := graph.SetOverride(pkg.Override{ID: "works", Edge: "bc", Disabled: true, Until: &until}); err != nil {
+		t.Fatal(err)
+	}
+	clock := start.Add(-time.Hour)
+	graph.SetClock(clock)
+
+	route, positions, err := MapMatch(graph, points, DefaultMatchOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := NewMatchResult(points, route, positions)
+	for i, want := range []string{"ab", "ab", "bc", "bc"} {
+		if got := result.Points[i].EdgeID; got != want {
+			t.Errorf("point %d matched to %q, want %s", i, got, want)
+		}
+	}
+	if !graph.Clock().Equal(clock) {
+		t.Errorf("graph clock left at %v, want %v", graph.Clock(), clock)
+	}
+}
+
+func TestClassPriorsChangeTheEmission(t *testing.T) {
+	graph := pkg.NewGraph()
+	for _, road := range []struct {
+		id, class string
+		latitude  float64
+	}{
+		{"north", "residential", 0.00002},
+		{"south", "primary", -0.00002},
+	} {
+		start, _ := graph.AddNode(road.id+"0", pkg.Point{Longitude: 0, Latitude: road.latitude})
+		end, _ := graph.AddNode(road.id+"1", pkg.Point{Longitude: 0.004, Latitude: road.latitude})
+		edge, err := graph.AddEdge(road.id, start, end, 10, []pkg.Point{start.Position, end.Position})
+		if err != nil {
+			t.Fatal(err)
+		}
+		edge.Attributes = &pkg.Attributes{Class: road.class}
+	}
+	Preprocess(graph)
+
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := make([]GPSPoint, 0)
+	for _, longitude := range []float64{0.0005, 0.0015, 0.0025, 0.0035} {
+		points = append(points, GPSPoint{Location: pkg.Point{Longitude: longitude}, Time: start.Add(time.Duration(len(points)) * 10 * time.Second)})
+	}
+
+	for _, test := range []struct {
+		priors map[string]float64
+		want   string
+	}{
+		{map[string]float64{"primary": 2}, "south"},
+		{map[string]float64{"residential": 2}, "north"},
+		{map[string]float64{"primary": 0.5}, "north"},
+	} {
+		options := DefaultMatchOptions
+		options.ClassPriors = test.priors
+		route, _, err := MapMatch(graph, points, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(route) != 1 || route[0].ID != test.want {
+			t.Errorf("priors %v matched %d edges starting with %s, want %s", test.priors, len(route), route[0].ID, test.want)
+		}
+	}
+	if got := DefaultMatchOptions.classLogPrior(graph.Edges["south"]); got != 0 {
+		t.Errorf("log prior without class priors %v, want 0", got)
+	}
+}
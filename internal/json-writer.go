@@ -0,0 +1,280 @@
+package internal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	Indent       = "  "
+)
+
+type OutputOptions struct {
+	Format  string
+	Compact bool
+	Gzip    bool
+}
+
+func OutputOptionsFor(path string, compact bool) OutputOptions {
+	options := OutputOptions{Format: FormatJSON, Compact: compact}
+	if strings.HasSuffix(path, ".gz") {
+		options.Gzip, path = true, strings.TrimSuffix(path, ".gz")
+	}
+	if strings.HasSuffix(path, ".ndjson") || strings.HasSuffix(path, ".jsonl") {
+		options.Format = FormatNDJSON
+	} else if strings.HasSuffix(path, BinaryExtension) {
+		options.Format = FormatBinary
+	}
+	return options
+}
+
+type output struct {
+	file   *os.File
+	gzip   *gzip.Writer
+	buffer *bufio.Writer
+}
+
+func createOutput(path string, compress bool) (*output, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &output{file: file}
+	if compress {
+		out.gzip = gzip.NewWriter(file)
+		out.buffer = bufio.NewWriter(out.gzip)
+	} else {
+		out.buffer = bufio.NewWriter(file)
+	}
+	return out, nil
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	return o.buffer.Write(p)
+}
+
+func (o *output) Close() error {
+	err := o.buffer.Flush()
+	if o.gzip != nil {
+		err = errors.Join(err, o.gzip.Close())
+	}
+	return errors.Join(err, o.file.Close())
+}
+
+type graphRecord struct {
+	Type string    `json:"type"`
+	Node *pkg.Node `json:"node,omitempty"`
+	Edge *pkg.Edge `json:"edge,omitempty"`
+}
+
+type MatchRecord struct {
+	Trace int
+	Index int
+	MatchedPoint
+}
+
+func (r *MatchResult) Records(trace int) []MatchRecord {
+	records := make([]MatchRecord, 0, len(r.Points))
+	for i, point := range r.Points {
+		records = append(records, MatchRecord{Trace: trace, Index: i, MatchedPoint: point})
+	}
+	return records
+}
+
+type jsonStream struct {
+	w       io.Writer
+	compact bool
+	err     error
+}
+
+func (s *jsonStream) write(text string) {
+	if s.err == nil {
+		_, s.err = io.WriteString(s.w, text)
+	}
+}
+
+func (s *jsonStream) newline(depth int) {
+	if !s.compact {
+		s.write("\n" + strings.Repeat(Indent, depth))
+	}
+}
+
+func (s *jsonStream) value(value interface{}, depth int) {
+	if s.err != nil {
+		return
+	}
+
+	var b []byte
+	if s.compact {
+		b, s.err = json.Marshal(value)
+	} else {
+		b, s.err = json.MarshalIndent(value, strings.Repeat(Indent, depth), Indent)
+	}
+	if s.err == nil {
+		_, s.err = s.w.Write(b)
+	}
+}
+
+func (s *jsonStream) key(key string, depth int) {
+	s.newline(depth)
+	s.value(key, depth)
+	if s.compact {
+		s.write(":")
+	} else {
+		s.write(": ")
+	}
+}
+
+func (s *jsonStream) array(length int, element func(int) interface{}, depth int) {
+	s.write("[")
+	for i := 0; i < length; i++ {
+		if i > 0 {
+			s.write(",")
+		}
+		s.newline(depth + 1)
+		s.value(element(i), depth+1)
+	}
+	if length > 0 {
+		s.newline(depth)
+	}
+	s.write("]")
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func objectOf[T any](s *jsonStream, m map[string]T, depth int) {
+	keys := sortedKeys(m)
+	s.write("{")
+	for i, key := range keys {
+		if i > 0 {
+			s.write(",")
+		}
+		s.key(key, depth+1)
+		s.value(m[key], depth+1)
+	}
+	if len(keys) > 0 {
+		s.newline(depth)
+	}
+	s.write("}")
+}
+
+func (s *jsonStream) graph(graph *pkg.Graph) {
+	s.write("{")
+	s.key("nodes", 1)
+	objectOf(s, graph.Nodes, 1)
+	s.write(",")
+	s.key("edges", 1)
+	objectOf(s, graph.Edges, 1)
+	s.newline(0)
+	s.write("}")
+}
+
+func (s *jsonStream) featureCollection(collection GeoJSONFeatureCollection) {
+	s.write("{")
+	s.key("type", 1)
+	s.value(collection.Type, 1)
+	s.write(",")
+	s.key("features", 1)
+	s.array(len(collection.Features), func(i int) interface{} { return collection.Features[i] }, 1)
+	s.newline(0)
+	s.write("}")
+}
+
+func WriteJSON(w io.Writer, obj interface{}, compact bool) error {
+	s := &jsonStream{w: w, compact: compact}
+	switch obj := obj.(type) {
+	case *pkg.Graph:
+		s.graph(obj)
+	case GeoJSONFeatureCollection:
+		s.featureCollection(obj)
+	case []*MatchResult:
+		records := make([]MatchRecord, 0)
+		for trace, result := range obj {
+			records = append(records, result.Records(trace)...)
+		}
+		s.array(len(records), func(i int) interface{} { return records[i] }, 0)
+	default:
+		if value := reflect.ValueOf(obj); value.Kind() == reflect.Slice {
+			s.array(value.Len(), func(i int) interface{} { return value.Index(i).Interface() }, 0)
+		} else {
+			s.value(obj, 0)
+		}
+	}
+	s.write("\n")
+	return s.err
+}
+
+func WriteNDJSON(w io.Writer, obj interface{}) error {
+	encoder := json.NewEncoder(w)
+	switch obj := obj.(type) {
+	case *pkg.Graph:
+		for _, id := range sortedKeys(obj.Nodes) {
+			if err := encoder.Encode(graphRecord{Type: "node", Node: obj.Nodes[id]}); err != nil {
+				return err
+			}
+		}
+		for _, id := range sortedKeys(obj.Edges) {
+			if err := encoder.Encode(graphRecord{Type: "edge", Edge: obj.Edges[id]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []*MatchResult:
+		for trace, result := range obj {
+			for _, record := range result.Records(trace) {
+				if err := encoder.Encode(record); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case GeoJSONFeatureCollection:
+		return WriteNDJSON(w, obj.Features)
+	}
+
+	if value := reflect.ValueOf(obj); value.Kind() == reflect.Slice {
+		for i := 0; i < value.Len(); i++ {
+			if err := encoder.Encode(value.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return encoder.Encode(obj)
+}
+
+func SaveObjectWith(obj interface{}, path string, options OutputOptions) (err error) {
+	out, err := createOutput(path, options.Gzip)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, out.Close())
+	}()
+
+	switch options.Format {
+	case FormatNDJSON:
+		return WriteNDJSON(out, obj)
+	case FormatBinary:
+		return writeBinary(out, obj)
+	}
+	return WriteJSON(out, obj, options.Compact)
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+func TestWriteJSONMatchRecordsPerPoint(t *testing.T) {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	edge := &pkg.Edge{ID: "ab", Poly: []pkg.Point{{Longitude: 0, Latitude: 0}, {Longitude: 0.002, Latitude: 0}}}
+	trace := func(longitudes ...float64) []GPSPoint {
+		points := make([]GPSPoint, len(longitudes))
+		for i, longitude := range longitudes {
+			points[i] = GPSPoint{Location: pkg.Point{Longitude: longitude, Latitude: 0.00001}, Time: start.Add(time.Duration(i) * time.Second)}
+		}
+		return points
+	}
+	results := []*MatchResult{
+		NewMatchResult(trace(0.0005, 0.0005, 0.0015), []*pkg.Edge{edge}, []int{0, 0, 0}),
+		NewMatchResult(trace(0.001), []*pkg.Edge{edge}, []int{0}),
+	}
+
+	var buffer bytes.Buffer
+	if err := WriteJSON(&buffer, results, true); err != nil {
+		t.Fatal(err)
+	}
+	var records []MatchRecord
+	if err := json.Unmarshal(buffer.Bytes(), &records); err != nil {
+		t.Fatalf("records are not a JSON array of records: %v\n%s", err, buffer.String())
+	}
+	if len(records) != 4 {
+		t.Fatalf("%d records, want one per input point (4)", len(records))
+	}
+	for i, want := range [][2]int{{0, 0}, {0, 1}, {0, 2}, {1, 0}} {
+		if records[i].Trace != want[0] || records[i].Index != want[1] || records[i].EdgeID != "ab" {
+			t.Errorf("record %d: trace %d index %d edge %q, want trace %d index %d edge ab", i, records[i].Trace, records[i].Index, records[i].EdgeID, want[0], want[1])
+		}
+	}
+}
+
+func TestWriteJSONMatchRecordsOnALoop(t *testing.T) {
+	graph, points := loopGraph(t), loopTrace()
+	// A repeated fix on the second pass along ab is dropped before matching
+	// but still gets its own record.
+	points = append(points, points[len(points)-1])
+	points[len(points)-1].Time = points[len(points)-1].Time.Add(time.Second)
+
+	route, positions, err := MapMatch(graph, points, DefaultMatchOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := WriteJSON(&buffer, []*MatchResult{NewMatchResult(points, route, positions)}, true); err != nil {
+		t.Fatal(err)
+	}
+	var records []MatchRecord
+	if err := json.Unmarshal(buffer.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(points) {
+		t.Fatalf("%d records, want one per input point (%d)", len(records), len(points))
+	}
+
+	want := []string{"ab", "ab", "ab", "bc", "bc", "bc", "cd", "cd", "cd", "da", "da", "da", "ab", "ab", "ab", "ab"}
+	for i, record := range records {
+		if record.Index != i || record.EdgeID != want[i] {
+			t.Errorf("record %d: index %d on %q, want %s", i, record.Index, record.EdgeID, want[i])
+		}
+		if record.Snapped == nil || record.Snapped.Distance(record.Location) > 2 {
+			t.Errorf("record %d snapped to %v, far from %v", i, record.Snapped, record.Location)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package internal
+
+import "github.com/ArshiaDadras/Ariadne/pkg"
+
+type MatchedPoint struct {
+	GPSPoint
+	Edge       *pkg.Edge       `json:"-"`
+	EdgeID     string          `json:",omitempty"`
+	Attributes *pkg.Attributes `json:",omitempty"`
+	Snapped    *pkg.Point      `json:",omitempty"`
+	Distance   float64         `json:",omitempty"`
+}
+
+type MatchResult struct {
+	Points []MatchedPoint
+	Route  []*pkg.Edge
+	Breaks []int
+}
+
+// NewMatchResult snaps every point onto the route edge at its position, as
+// returned by MapMatch.
+func NewMatchResult(points []GPSPoint, route []*pkg.Edge, positions []int) *MatchResult {
+	result := &MatchResult{
+		Points: make([]MatchedPoint, 0, len(points)),
+		Route:  route,
+		Breaks: make([]int, 0),
+	}
+
+	for i := 1; i < len(route); i++ {
+		if route[i-1].End != route[i].Start {
+			result.Breaks = append(result.Breaks, i)
+		}
+	}
+
+	for i, point := range points {
+		matched := MatchedPoint{GPSPoint: point}
+		if position := positions[i]; position >= 0 {
+			edge := route[position]
+			closest := point.Location.ClosestPointOnEdge(edge)
+			matched.Edge, matched.EdgeID, matched.Snapped, matched.Distance = edge, edge.ID, &closest, point.Location.Distance(closest)
+			matched.Attributes = edge.Attributes
+		}
+		result.Points = append(result.Points, matched)
+	}
+	return result
+}
+
+func (r *MatchResult) Pieces() [][]*pkg.Edge {
+	pieces, start := make([][]*pkg.Edge, 0), 0
+	for _, end := range append(r.Breaks, len(r.Route)) {
+		if end > start {
+			pieces = append(pieces, r.Route[start:end])
+		}
+		start = end
+	}
+	return pieces
+}
+
+func MergePolyline(edges []*pkg.Edge) []pkg.Point {
+	points := make([]pkg.Point, 0)
+	for _, edge := range edges {
+		for _, point := range edge.Poly {
+			if len(points) == 0 || points[len(points)-1] != point {
+				points = append(points, point)
+			}
+		}
+	}
+	return points
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+// loopGraph is a one-way block a→b→c→d→a, 0.002° on each side.
+func loopGraph(t *testing.T) *pkg.Graph {
+	t.Helper()
+	graph := pkg.NewGraph()
+	corners := []pkg.Point{{Longitude: 0, Latitude: 0}, {Longitude: 0.002, Latitude: 0}, {Longitude: 0.002, Latitude: 0.002}, {Longitude: 0, Latitude: 0.002}}
+	nodes := make([]*pkg.Node, len(corners))
+	for i, corner := range corners {
+		nodes[i], _ = graph.AddNode(string(rune('a'+i)), corner)
+	}
+	for i, start := range nodes {
+		end := nodes[(i+1)%len(nodes)]
+		if _, err := graph.AddEdge(start.ID+end.ID, start, end, 10, []pkg.Point{start.Position, end.Position}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Preprocess(graph)
+	return graph
+}
+
+// loopTrace drives once around the block and then along ab again.
+func loopTrace() []GPSPoint {
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	corners := []pkg.Point{{Longitude: 0, Latitude: 0}, {Longitude: 0.002, Latitude: 0}, {Longitude: 0.002, Latitude: 0.002}, {Longitude: 0, Latitude: 0.002}, {Longitude: 0, Latitude: 0}, {Longitude: 0.002, Latitude: 0}}
+	points := make([]GPSPoint, 0)
+	for i := 1; i < len(corners); i++ {
+		for _, fraction := range []float64{0.25, 0.5, 0.75} {
+			location := pkg.Point{
+				Longitude: corners[i-1].Longitude + fraction*(corners[i].Longitude-corners[i-1].Longitude) + 0.00001,
+				Latitude:  corners[i-1].Latitude + fraction*(corners[i].Latitude-corners[i-1].Latitude) + 0.00001,
+			}
+			points = append(points, GPSPoint{Location: location, Time: start.Add(time.Duration(len(points)) * 5 * time.Second)})
+		}
+	}
+	return points
+}
+
+func TestNewMatchResultSnapsToTheChosenPassOfALoop(t *testing.T) {
+	graph, points := loopGraph(t), loopTrace()
+	route, positions, err := MapMatch(graph, points, DefaultMatchOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(route))
+	for i, edge := range route {
+		ids[i] = edge.ID
+	}
+	if want := []string{"ab", "bc", "cd", "da", "ab"}; len(ids) != len(want) || ids[0] != want[0] || ids[4] != want[4] {
+		t.Fatalf("route %v, want %v", ids, want)
+	}
+
+	result := NewMatchResult(points, route, positions)
+	for i, point := range result.Points {
+		if want := i / 3; positions[i] != want || point.Edge != route[want] {
+			t.Errorf("point %d: position %d on %s, want %d", i, positions[i], point.EdgeID, want)
+		}
+		if point.Snapped == nil || point.Distance > 2 {
+			t.Errorf("point %d snapped %v metres away onto %s", i, point.Distance, point.EdgeID)
+		}
+	}
+}
+
+func TestNewMatchResultKeepsNoisyPointsOnTheChosenEdge(t *testing.T) {
+	// Out along ab and back along cd, a parallel road 11 m to the north. The
+	// fourth point drifts closer to cd but Viterbi keeps it on ab.
+	graph := pkg.NewGraph()
+	a, _ := graph.AddNode("a", pkg.Point{Longitude: 0, Latitude: 0})
+	b, _ := graph.AddNode("b", pkg.Point{Longitude: 0.004, Latitude: 0})
+	c, _ := graph.AddNode("c", pkg.Point{Longitude: 0.004, Latitude: 0.0001})
+	d, _ := graph.AddNode("d", pkg.Point{Longitude: 0, Latitude: 0.0001})
+	for _, edge := range [][2]*pkg.Node{{a, b}, {b, c}, {c, d}, {d, a}} {
+		if _, err := graph.AddEdge(edge[0].ID+edge[1].ID, edge[0], edge[1], 10, []pkg.Point{edge[0].Position, edge[1].Position}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Preprocess(graph)
+
+	start, points := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC), make([]GPSPoint, 0)
+	for i, longitude := range []float64{0.0005, 0.001, 0.0015, 0.002, 0.0025, 0.003, 0.0035} {
+		latitude := 0.0
+		if i == 3 {
+			latitude = 0.00006
+		}
+		points = append(points, GPSPoint{Location: pkg.Point{Longitude: longitude, Latitude: latitude}, Time: start.Add(time.Duration(len(points)) * 5 * time.Second)})
+	}
+	for _, longitude := range []float64{0.0035, 0.0025, 0.0015, 0.0005} {
+		points = append(points, GPSPoint{Location: pkg.Point{Longitude: longitude, Latitude: 0.0001}, Time: start.Add(time.Duration(len(points)) * 5 * time.Second)})
+	}
+
+	route, positions, err := MapMatch(graph, points, DefaultMatchOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, point := range NewMatchResult(points, route, positions).Points {
+		want := "ab"
+		if i >= 7 {
+			want = "cd"
+		}
+		if point.EdgeID != want {
+			t.Errorf("point %d matched to %q, want %s", i, point.EdgeID, want)
+		}
+	}
+}
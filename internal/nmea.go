@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+const (
+	KnotsToMetersPerSecond = 1852.0 / 3600.0
+)
+
+type nmeaFix struct {
+	TimeOfDay time.Duration
+	Date      *time.Time
+	Location  *pkg.Point
+	Status    string
+	Point     GPSPoint
+}
+
+// valid trusts the GGA fix quality over the RMC status when both are present.
+func (f *nmeaFix) valid() bool {
+	if f.Point.FixQuality != nil {
+		return *f.Point.FixQuality > 0
+	}
+	return f.Status == "A"
+}
+
+// nmeaChecksum returns the sentence between $ and *, verifying the checksum
+// when there is one.
+func nmeaChecksum(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	star := strings.LastIndexByte(line, '*')
+	if !strings.HasPrefix(line, "$") {
+		return "", false
+	}
+	if star < 0 {
+		return line[1:], true
+	}
+	if len(line) < star+3 {
+		return "", false
+	}
+
+	var sum byte
+	for i := 1; i < star; i++ {
+		sum ^= line[i]
+	}
+	expected, err := strconv.ParseUint(line[star+1:star+3], 16, 8)
+	if err != nil || byte(expected) != sum {
+		return "", false
+	}
+	return line[1:star], true
+}
+
+func parseNMEATime(value string) (time.Duration, bool) {
+	if len(value) < 6 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(value[0:2])
+	minutes, err2 := strconv.Atoi(value[2:4])
+	seconds, err3 := strconv.ParseFloat(value[4:], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)), true
+}
+
+func parseNMEADate(value string) (*time.Time, bool) {
+	date, err := time.Parse("020106", value)
+	if err != nil {
+		return nil, false
+	}
+	return &date, true
+}
+
+func parseNMEACoordinate(value, hemisphere string, degreeDigits int) (float64, bool) {
+	if len(value) < degreeDigits {
+		return 0, false
+	}
+	degrees, err1 := strconv.ParseFloat(value[:degreeDigits], 64)
+	minutes, err2 := strconv.ParseFloat(value[degreeDigits:], 64)
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+
+	coordinate := degrees + minutes/60
+	switch hemisphere {
+	case "S", "W":
+		return -coordinate, true
+	case "N", "E":
+		return coordinate, true
+	}
+	return 0, false
+}
+
+func parseNMEALocation(fields []string) (*pkg.Point, bool) {
+	latitude, ok1 := parseNMEACoordinate(fields[0], fields[1], 2)
+	longitude, ok2 := parseNMEACoordinate(fields[2], fields[3], 3)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	return &pkg.Point{Longitude: longitude, Latitude: latitude}, true
+}
+
+func parseNMEAFloat(value string, scale float64) *float64 {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+	number *= scale
+	return &number
+}
+
+func field(fields []string, i int) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return ""
+}
+
+func (f *nmeaFix) applyRMC(fields []string) {
+	if date, ok := parseNMEADate(field(fields, 9)); ok {
+		f.Date = date
+	}
+	if f.Status = field(fields, 2); f.Status != "A" {
+		return
+	}
+	if location, ok := parseNMEALocation([]string{field(fields, 3), field(fields, 4), field(fields, 5), field(fields, 6)}); ok && f.Location == nil {
+		f.Location = location
+	}
+	if speed := parseNMEAFloat(field(fields, 7), KnotsToMetersPerSecond); speed != nil && f.Point.Speed == nil {
+		f.Point.Speed = speed
+	}
+	if course := parseNMEAFloat(field(fields, 8), 1); course != nil && f.Point.Course == nil {
+		f.Point.Course = course
+	}
+}
+
+func (f *nmeaFix) applyGGA(fields []string) {
+	quality, _ := strconv.Atoi(field(fields, 6))
+	if f.Point.FixQuality = &quality; quality == 0 {
+		return
+	}
+	if location, ok := parseNMEALocation([]string{field(fields, 2), field(fields, 3), field(fields, 4), field(fields, 5)}); ok {
+		f.Location = location
+	}
+	f.Point.HDOP = parseNMEAFloat(field(fields, 8), 1)
+	f.Point.Elevation = parseNMEAFloat(field(fields, 9), 1)
+}
+
+func (f *nmeaFix) applyVTG(fields []string) {
+	if course := parseNMEAFloat(field(fields, 1), 1); course != nil {
+		f.Point.Course = course
+	}
+	if speed := parseNMEAFloat(field(fields, 7), 1000.0/3600.0); speed != nil {
+		f.Point.Speed = speed
+	} else if speed := parseNMEAFloat(field(fields, 5), KnotsToMetersPerSecond); speed != nil {
+		f.Point.Speed = speed
+	}
+}
+
+type nmeaReader struct {
+	points  []GPSPoint
+	current *nmeaFix
+	date    *time.Time
+	lastTOD time.Duration
+}
+
+func (r *nmeaReader) flush() {
+	fix := r.current
+	r.current = nil
+	if fix == nil {
+		return
+	}
+
+	if fix.Date != nil {
+		r.date = fix.Date
+	} else if r.date != nil && fix.TimeOfDay < r.lastTOD {
+		next := r.date.AddDate(0, 0, 1)
+		r.date = &next
+	}
+	r.lastTOD = fix.TimeOfDay
+
+	if !fix.valid() || fix.Location == nil || r.date == nil {
+		return
+	}
+	fix.Point.Location = *fix.Location
+	fix.Point.Time = r.date.Add(fix.TimeOfDay)
+	r.points = append(r.points, fix.Point)
+}
+
+func (r *nmeaReader) read(sentence string) {
+	fields := strings.Split(sentence, ",")
+	if len(fields[0]) < 5 {
+		return
+	}
+
+	kind := fields[0][len(fields[0])-3:]
+	if kind == "VTG" {
+		if r.current != nil {
+			r.current.applyVTG(fields)
+		}
+		return
+	}
+	if kind != "RMC" && kind != "GGA" {
+		return
+	}
+
+	timeOfDay, ok := parseNMEATime(field(fields, 1))
+	if !ok {
+		return
+	}
+	if r.current == nil || r.current.TimeOfDay != timeOfDay {
+		r.flush()
+		r.current = &nmeaFix{TimeOfDay: timeOfDay}
+	}
+
+	if kind == "RMC" {
+		r.current.applyRMC(fields)
+	} else {
+		r.current.applyGGA(fields)
+	}
+}
+
+func ParseNMEA(path string) ([]GPSPoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := &nmeaReader{points: make([]GPSPoint, 0)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if sentence, ok := nmeaChecksum(scanner.Text()); ok {
+			reader.read(sentence)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	reader.flush()
+
+	SortByTime(reader.points)
+	return reader.points, nil
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nmeaSentence wraps body in $ and a valid checksum.
+func nmeaSentence(body string) string {
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	return fmt.Sprintf("$%s*%02X", body, sum)
+}
+
+func parseNMEALines(t *testing.T, lines ...string) []GPSPoint {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "trace.nmea")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	points, err := ParseNMEA(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return points
+}
+
+func pointTimes(points []GPSPoint) []string {
+	times := make([]string, len(points))
+	for i, point := range points {
+		times[i] = point.Time.Format(time.RFC3339)
+	}
+	return times
+}
+
+func TestParseNMEAChecksums(t *testing.T) {
+	good := nmeaSentence("GPRMC,080000,A,5000.000,N,01000.000,E,10.0,90.0,010124,,")
+	corrupt := strings.Replace(nmeaSentence("GPRMC,080001,A,5000.000,N,01000.001,E,10.0,90.0,010124,,"), "080001", "080009", 1)
+	unchecked := "$GPRMC,080002,A,5000.000,N,01000.002,E,10.0,90.0,010124,,"
+	truncated := nmeaSentence("GPRMC,080003,A,5000.000,N,01000.003,E,10.0,90.0,010124,,")
+	truncated = truncated[:len(truncated)-1]
+
+	points := parseNMEALines(t, good, corrupt, unchecked, truncated, "GPRMC,080004,A,5000.000,N,01000.004,E,,,010124,,")
+	if got, want := pointTimes(points), []string{"2024-01-01T08:00:00Z", "2024-01-01T08:00:02Z"}; !slices.Equal(got, want) {
+		t.Errorf("points at %v, want %v", got, want)
+	}
+	if len(points) > 0 && (points[0].Speed == nil || *points[0].Speed != 10*KnotsToMetersPerSecond) {
+		t.Errorf("first point speed %v, want 10 knots", points[0].Speed)
+	}
+}
+
+func TestParseNMEARollsTheDateOverAtMidnight(t *testing.T) {
+	points := parseNMEALines(t,
+		nmeaSentence("GPRMC,235958,A,5000.000,N,01000.000,E,,,311224,,"),
+		nmeaSentence("GPGGA,235959,5000.000,N,01000.001,E,1,8,0.9,100.0,M,,,,"),
+		nmeaSentence("GPGGA,000000,5000.000,N,01000.002,E,1,8,0.9,100.0,M,,,,"),
+		nmeaSentence("GPGGA,000001,5000.000,N,01000.003,E,1,8,0.9,100.0,M,,,,"),
+	)
+	want := []string{"2024-12-31T23:59:58Z", "2024-12-31T23:59:59Z", "2025-01-01T00:00:00Z", "2025-01-01T00:00:01Z"}
+	if got := pointTimes(points); !slices.Equal(got, want) {
+		t.Errorf("points at %v, want %v", got, want)
+	}
+}
+
+func TestParseNMEASkipsInvalidFixes(t *testing.T) {
+	points := parseNMEALines(t,
+		nmeaSentence("GPRMC,080000,A,5000.000,N,01000.000,E,,,010124,,"),
+		// No fix from either sentence.
+		nmeaSentence("GPRMC,080001,V,5000.000,N,01000.001,E,,,010124,,"),
+		nmeaSentence("GPGGA,080001,5000.000,N,01000.001,E,0,0,,,M,,,,"),
+		// RMC alone without a fix.
+		nmeaSentence("GPRMC,080002,V,5000.000,N,01000.002,E,,,010124,,"),
+		// GGA has a fix although RMC does not.
+		nmeaSentence("GPRMC,080003,V,,,,,,,010124,,"),
+		nmeaSentence("GPGGA,080003,5000.000,N,01000.003,E,1,8,0.9,100.0,M,,,,"),
+		// GGA without a fix overrules RMC.
+		nmeaSentence("GPGGA,080004,5000.000,N,01000.004,E,0,0,,,M,,,,"),
+		nmeaSentence("GPRMC,080004,A,5000.000,N,01000.004,E,,,010124,,"),
+	)
+	want := []string{"2024-01-01T08:00:00Z", "2024-01-01T08:00:03Z"}
+	if got := pointTimes(points); !slices.Equal(got, want) {
+		t.Fatalf("points at %v, want %v", got, want)
+	}
+	if fix := points[1]; fix.FixQuality == nil || *fix.FixQuality != 1 || fix.Location.Longitude != 10+0.003/60 {
+		t.Errorf("GGA fix %+v, want quality 1 at its own location", fix)
+	}
+}
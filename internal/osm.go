@@ -0,0 +1,408 @@
+package internal
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+const (
+	MilesToKilometers = 1.609344
+)
+
+var (
+	ErrUnknownProfile = errors.New("unknown profile")
+)
+
+type OSMProfile struct {
+	Name         string
+	Speeds       map[string]float64
+	DefaultSpeed float64
+	AccessTags   []string
+	OnewayTags   []string
+	UseMaxspeed  bool
+}
+
+var OSMAttributeTags = []string{"ref", "maxspeed", "junction", "bridge", "tunnel"}
+
+var OSMProfiles = map[string]OSMProfile{
+	"car": {
+		Name: "car",
+		Speeds: map[string]float64{
+			"motorway": 110, "motorway_link": 60,
+			"trunk": 90, "trunk_link": 50,
+			"primary": 70, "primary_link": 50,
+			"secondary": 60, "secondary_link": 40,
+			"tertiary": 50, "tertiary_link": 30,
+			"unclassified": 40, "residential": 30, "road": 30,
+			"service": 20, "living_street": 10,
+		},
+		DefaultSpeed: 30,
+		AccessTags:   []string{"access", "vehicle", "motor_vehicle", "motorcar"},
+		OnewayTags:   []string{"oneway"},
+		UseMaxspeed:  true,
+	},
+	"bike": {
+		Name: "bike",
+		Speeds: map[string]float64{
+			"cycleway": 18,
+			"primary":  18, "primary_link": 18,
+			"secondary": 18, "secondary_link": 18,
+			"tertiary": 18, "tertiary_link": 18,
+			"unclassified": 16, "residential": 16, "road": 16,
+			"service": 12, "living_street": 10,
+			"track": 12, "path": 12,
+		},
+		DefaultSpeed: 12,
+		AccessTags:   []string{"access", "vehicle", "bicycle"},
+		OnewayTags:   []string{"oneway:bicycle", "oneway"},
+	},
+	"foot": {
+		Name: "foot",
+		Speeds: map[string]float64{
+			"footway": 5, "pedestrian": 5, "path": 5, "steps": 2, "track": 5,
+			"primary": 5, "primary_link": 5,
+			"secondary": 5, "secondary_link": 5,
+			"tertiary": 5, "tertiary_link": 5,
+			"unclassified": 5, "residential": 5, "road": 5,
+			"service": 5, "living_street": 5, "cycleway": 5,
+		},
+		DefaultSpeed: 5,
+		AccessTags:   []string{"access", "foot"},
+	},
+}
+
+type osmWay struct {
+	ID    int64
+	Nodes []int64
+	Tags  map[string]string
+}
+
+type osmData struct {
+	Nodes map[int64]pkg.Point
+	Ways  []osmWay
+}
+
+func GetOSMProfile(name string) (OSMProfile, error) {
+	profile, ok := OSMProfiles[name]
+	if !ok {
+		return OSMProfile{}, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+	return profile, nil
+}
+
+func (p *OSMProfile) Accepts(tags map[string]string) bool {
+	highway, ok := tags["highway"]
+	if !ok || tags["area"] == "yes" {
+		return false
+	}
+
+	if _, ok := p.Speeds[highway]; !ok {
+		return false
+	}
+
+	allowed := true
+	for _, tag := range p.AccessTags {
+		switch tags[tag] {
+		case "no", "private":
+			allowed = false
+		case "yes", "designated", "permissive", "destination":
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+func parseMaxspeed(value string) (float64, bool) {
+	value = strings.TrimSpace(strings.Split(value, ";")[0])
+	if value == "walk" {
+		return 5, true
+	}
+
+	scale := 1.0
+	if strings.HasSuffix(value, "mph") {
+		value, scale = strings.TrimSpace(strings.TrimSuffix(value, "mph")), MilesToKilometers
+	} else if strings.HasSuffix(value, "km/h") {
+		value = strings.TrimSpace(strings.TrimSuffix(value, "km/h"))
+	}
+
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil || speed <= 0 {
+		return 0, false
+	}
+	return speed * scale, true
+}
+
+func (p *OSMProfile) Speed(tags map[string]string) float64 {
+	speed, ok := p.Speeds[tags["highway"]]
+	if !ok {
+		speed = p.DefaultSpeed
+	}
+	if p.UseMaxspeed {
+		if maxspeed, ok := parseMaxspeed(tags["maxspeed"]); ok {
+			speed = maxspeed
+		}
+	}
+	return speed
+}
+
+func (p *OSMProfile) Direction(tags map[string]string) (forward, backward bool) {
+	if len(p.OnewayTags) == 0 {
+		return true, true
+	}
+
+	for _, tag := range p.OnewayTags {
+		switch tags[tag] {
+		case "yes", "true", "1":
+			return true, false
+		case "-1", "reverse":
+			return false, true
+		case "no", "false", "0":
+			return true, true
+		}
+	}
+
+	if tags["highway"] == "motorway" || tags["junction"] == "roundabout" || tags["junction"] == "circular" {
+		return true, false
+	}
+	return true, true
+}
+
+func buildOSMGraph(graph *pkg.Graph, data *osmData, profile OSMProfile) error {
+	uses := make(map[int64]int)
+	for _, way := range data.Ways {
+		for i, node := range way.Nodes {
+			uses[node]++
+			if i == 0 || i == len(way.Nodes)-1 {
+				uses[node]++
+			}
+		}
+	}
+
+	for _, way := range data.Ways {
+		forward, backward := profile.Direction(way.Tags)
+		speed := profile.Speed(way.Tags) * 1000.0 / 3600.0
+		attributes := osmAttributes(way.Tags, profile)
+
+		for part, piece := range splitWay(way, data.Nodes, uses) {
+			id := strconv.FormatInt(way.ID, 10) + "_" + strconv.Itoa(part)
+			if err := addOSMPiece(graph, id, way.ID, piece, data.Nodes, speed, forward, backward, attributes); err != nil {
+				return fmt.Errorf("way %d: %w", way.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func osmAttributes(tags map[string]string, profile OSMProfile) *pkg.Attributes {
+	lanes, _ := parseLanes(tags["lanes"])
+	attributes := &pkg.Attributes{
+		Class:   tags["highway"],
+		Name:    tags["name"],
+		Lanes:   lanes,
+		Surface: tags["surface"],
+	}
+	for _, tag := range profile.AccessTags {
+		if value, ok := tags[tag]; ok {
+			attributes.Access = value
+		}
+	}
+	for _, tag := range OSMAttributeTags {
+		if value, ok := tags[tag]; ok {
+			if attributes.Tags == nil {
+				attributes.Tags = make(map[string]string)
+			}
+			attributes.Tags[tag] = value
+		}
+	}
+	return attributes
+}
+
+func splitWay(way osmWay, nodes map[int64]pkg.Point, uses map[int64]int) (pieces [][]int64) {
+	piece := make([]int64, 0)
+	for _, node := range way.Nodes {
+		if _, ok := nodes[node]; !ok {
+			if len(piece) > 1 {
+				pieces = append(pieces, piece)
+			}
+			piece = make([]int64, 0)
+			continue
+		}
+		if len(piece) > 0 && piece[len(piece)-1] == node {
+			continue
+		}
+
+		piece = append(piece, node)
+		if len(piece) > 1 && uses[node] > 1 {
+			pieces = append(pieces, piece)
+			piece = []int64{node}
+		}
+	}
+	if len(piece) > 1 {
+		pieces = append(pieces, piece)
+	}
+	return splitLoops(pieces)
+}
+
+// splitLoops halves pieces that end where they start, such as closed ways, so
+// that none of them becomes a self-loop.
+func splitLoops(pieces [][]int64) [][]int64 {
+	split := make([][]int64, 0, len(pieces))
+	for _, piece := range pieces {
+		if last := len(piece) - 1; last > 1 && piece[0] == piece[last] {
+			split = append(split, piece[:last/2+1], piece[last/2:])
+		} else {
+			split = append(split, piece)
+		}
+	}
+	return split
+}
+
+func osmNode(graph *pkg.Graph, id int64, position pkg.Point) (*pkg.Node, error) {
+	return getOrCreateNode(graph, strconv.FormatInt(id, 10), position, nil)
+}
+
+func addOSMPiece(graph *pkg.Graph, id string, wayID int64, piece []int64, nodes map[int64]pkg.Point, speed float64, forward, backward bool, attributes *pkg.Attributes) error {
+	points := make([]pkg.Point, 0, len(piece))
+	for _, node := range piece {
+		points = append(points, nodes[node])
+	}
+
+	first, last := piece[0], piece[len(piece)-1]
+	start, err := osmNode(graph, first, nodes[first])
+	if err != nil {
+		return err
+	}
+	end, err := osmNode(graph, last, nodes[last])
+	if err != nil {
+		return err
+	}
+
+	var edges []*pkg.Edge
+	if forward {
+		edges, err = addRoad(graph, id, start, end, speed, points, backward)
+	} else if backward {
+		edges, err = addRoad(graph, id, end, start, speed, reversePoints(points), false)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, edge := range edges {
+		edge.OSMWayID, edge.OSMNodeIDs, edge.Attributes = wayID, append([]int64(nil), piece...), attributes
+		if edge.Start != strconv.FormatInt(first, 10) {
+			reverseIDs(edge.OSMNodeIDs)
+		}
+	}
+	return nil
+}
+
+func reverseIDs(ids []int64) {
+	for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+		ids[i], ids[j] = ids[j], ids[i]
+	}
+}
+
+func osmTags(element xml.StartElement, decoder *xml.Decoder) (map[string]string, []int64, error) {
+	tags, refs := make(map[string]string), make([]int64, 0)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "tag":
+				tags[attr(token, "k")] = attr(token, "v")
+			case "nd":
+				ref, err := strconv.ParseInt(attr(token, "ref"), 10, 64)
+				if err != nil {
+					return nil, nil, err
+				}
+				refs = append(refs, ref)
+			}
+		case xml.EndElement:
+			if token.Name.Local == element.Name.Local {
+				return tags, refs, nil
+			}
+		}
+	}
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, attribute := range element.Attr {
+		if attribute.Name.Local == name {
+			return attribute.Value
+		}
+	}
+	return ""
+}
+
+func readOSMXML(reader io.Reader, profile OSMProfile) (*osmData, error) {
+	data := &osmData{Nodes: make(map[int64]pkg.Point)}
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return data, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch element.Name.Local {
+		case "node":
+			id, err := strconv.ParseInt(attr(element, "id"), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			latitude, err := strconv.ParseFloat(attr(element, "lat"), 64)
+			if err != nil {
+				return nil, err
+			}
+			longitude, err := strconv.ParseFloat(attr(element, "lon"), 64)
+			if err != nil {
+				return nil, err
+			}
+			data.Nodes[id] = pkg.Point{Longitude: longitude, Latitude: latitude}
+		case "way":
+			id, err := strconv.ParseInt(attr(element, "id"), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			tags, refs, err := osmTags(element, decoder)
+			if err != nil {
+				return nil, err
+			}
+			if profile.Accepts(tags) {
+				data.Ways = append(data.Ways, osmWay{ID: id, Nodes: refs, Tags: tags})
+			}
+		}
+	}
+}
+
+func BuildOSMNetwork(graph *pkg.Graph, path string, profile OSMProfile) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := readOSMXML(file, profile)
+	if err != nil {
+		return err
+	}
+	return buildOSMGraph(graph, data, profile)
+}
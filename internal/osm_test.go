@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+func TestOSMProfileAccepts(t *testing.T) {
+	for _, test := range []struct {
+		profile string
+		tags    map[string]string
+		want    bool
+	}{
+		{"car", map[string]string{"highway": "residential"}, true},
+		{"car", map[string]string{"highway": "footway"}, false},
+		{"car", map[string]string{"highway": "footway", "access": "yes"}, false},
+		{"car", map[string]string{"highway": "footway", "motorcar": "designated"}, false},
+		{"car", map[string]string{"highway": "residential", "access": "private"}, false},
+		{"car", map[string]string{"highway": "residential", "access": "no", "motorcar": "yes"}, true},
+		{"car", map[string]string{"highway": "residential", "access": "yes", "motor_vehicle": "no"}, false},
+		{"car", map[string]string{"highway": "residential", "area": "yes"}, false},
+		{"bike", map[string]string{"highway": "motorway", "bicycle": "yes"}, false},
+		{"foot", map[string]string{"highway": "footway", "access": "no", "foot": "permissive"}, true},
+	} {
+		profile, err := GetOSMProfile(test.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := profile.Accepts(test.tags); got != test.want {
+			t.Errorf("%s accepts %v = %v, want %v", test.profile, test.tags, got, test.want)
+		}
+	}
+}
+
+func TestBuildOSMNetwork(t *testing.T) {
+	path := writeFile(t, "network.osm", `<osm version="0.6">
+		<node id="1" lat="0" lon="0"/><node id="2" lat="0" lon="0.001"/><node id="3" lat="0" lon="0.002"/>
+		<node id="4" lat="0.001" lon="0.003"/><node id="5" lat="0" lon="0.004"/><node id="6" lat="-0.001" lon="0.003"/>
+		<node id="7" lat="0.01" lon="0"/><node id="8" lat="0.01" lon="0.001"/><node id="9" lat="0.011" lon="0"/>
+		<way id="10"><nd ref="1"/><nd ref="2"/><tag k="highway" v="residential"/><tag k="oneway" v="-1"/></way>
+		<way id="11"><nd ref="2"/><nd ref="3"/><tag k="highway" v="residential"/><tag k="maxspeed" v="30 mph"/></way>
+		<way id="12"><nd ref="3"/><nd ref="4"/><nd ref="5"/><nd ref="6"/><nd ref="3"/><tag k="highway" v="primary"/><tag k="junction" v="roundabout"/></way>
+		<way id="13"><nd ref="7"/><nd ref="8"/><nd ref="9"/><nd ref="7"/><tag k="highway" v="service"/><tag k="maxspeed" v="none"/></way>
+	</osm>`)
+	profile, err := GetOSMProfile("car")
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := pkg.NewGraph()
+	if err := BuildOSMNetwork(graph, path, profile); err != nil {
+		t.Fatal(err)
+	}
+
+	mph := 30 * MilesToKilometers * 1000 / 3600
+	for id, want := range map[string]struct {
+		nodes []int64
+		speed float64
+	}{
+		"10_0":                     {[]int64{2, 1}, profile.Speeds["residential"] * 1000 / 3600},
+		"11_0":                     {[]int64{2, 3}, mph},
+		"11_0" + pkg.ReverseSuffix: {[]int64{3, 2}, mph},
+		"12_0":                     {[]int64{3, 4, 5}, profile.Speeds["primary"] * 1000 / 3600},
+		"12_1":                     {[]int64{5, 6, 3}, profile.Speeds["primary"] * 1000 / 3600},
+		"13_0":                     {[]int64{7, 8}, profile.Speeds["service"] * 1000 / 3600},
+		"13_1" + pkg.ReverseSuffix: {[]int64{7, 9, 8}, profile.Speeds["service"] * 1000 / 3600},
+	} {
+		edge := graph.Edges[id]
+		if edge == nil {
+			t.Errorf("edge %s is missing", id)
+			continue
+		}
+		first, last := strconv.FormatInt(want.nodes[0], 10), strconv.FormatInt(want.nodes[len(want.nodes)-1], 10)
+		if edge.Start != first || edge.End != last || !slices.Equal(edge.OSMNodeIDs, want.nodes) || len(edge.Poly) != len(want.nodes) {
+			t.Errorf("edge %s runs %s→%s through %v, want %s→%s through %v", id, edge.Start, edge.End, edge.OSMNodeIDs, first, last, want.nodes)
+		}
+		if wayID, _ := strconv.ParseInt(id[:2], 10, 64); edge.OSMWayID != wayID {
+			t.Errorf("edge %s has way ID %d, want %d", id, edge.OSMWayID, wayID)
+		}
+		if math.Abs(edge.Speed-want.speed) > 1e-9 {
+			t.Errorf("edge %s has speed %v, want %v", id, edge.Speed, want.speed)
+		}
+	}
+	if graph.Edges["10_0"+pkg.ReverseSuffix] != nil || graph.Edges["12_0"+pkg.ReverseSuffix] != nil {
+		t.Error("one-way ways were imported in both directions")
+	}
+
+	for id, edge := range graph.Edges {
+		if edge.Start == edge.End {
+			t.Errorf("edge %s is a self-loop at %s", id, edge.Start)
+		}
+		if node := graph.Nodes[edge.Start]; node.Position != edge.Poly[0] {
+			t.Errorf("edge %s starts at %v, away from node %s at %v", id, edge.Poly[0], node.ID, node.Position)
+		}
+	}
+}
+
+func TestParseMaxspeed(t *testing.T) {
+	for value, want := range map[string]float64{
+		"50":      50,
+		"50 km/h": 50,
+		"20 mph":  20 * MilesToKilometers,
+		"walk":    5,
+		"30;50":   30,
+		"none":    0,
+		"signals": 0,
+		"-10":     0,
+		"":        0,
+	} {
+		got, ok := parseMaxspeed(value)
+		if ok != (want > 0) || math.Abs(got-want) > 1e-9 {
+			t.Errorf("parseMaxspeed(%q) = %v, %v, want %v", value, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package internal
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+const (
+	FormatJSON = "json"
+)
+
+func coordinates(point pkg.Point) []float64 {
+	return []float64{point.Longitude, point.Latitude}
+}
+
+func lineCoordinates(points []pkg.Point) [][]float64 {
+	line := make([][]float64, 0, len(points))
+	for _, point := range points {
+		line = append(line, coordinates(point))
+	}
+	return line
+}
+
+func newFeature(kind string, coordinates interface{}, properties map[string]interface{}) (GeoJSONFeature, error) {
+	raw, err := json.Marshal(coordinates)
+	if err != nil {
+		return GeoJSONFeature{}, err
+	}
+	return GeoJSONFeature{
+		Type:       "Feature",
+		Properties: properties,
+		Geometry:   &GeoJSONGeometry{Type: kind, Coordinates: raw},
+	}, nil
+}
+
+func addAttributes(properties map[string]interface{}, attributes *pkg.Attributes) {
+	if attributes.Empty() {
+		return
+	}
+	for key, value := range map[string]string{"class": attributes.Class, "name": attributes.Name, "access": attributes.Access, "surface": attributes.Surface} {
+		if value != "" {
+			properties[key] = value
+		}
+	}
+	if attributes.Lanes > 0 {
+		properties["lanes"] = attributes.Lanes
+	}
+	for key, value := range attributes.Tags {
+		if _, ok := properties[key]; !ok {
+			properties[key] = value
+		}
+	}
+}
+
+func (r *MatchResult) features(trace int) ([]GeoJSONFeature, error) {
+	features := make([]GeoJSONFeature, 0)
+	add := func(kind string, coordinates interface{}, properties map[string]interface{}) error {
+		properties["trace"] = trace
+		feature, err := newFeature(kind, coordinates, properties)
+		if err != nil {
+			return err
+		}
+		features = append(features, feature)
+		return nil
+	}
+
+	pieces := r.Pieces()
+	for i, piece := range pieces {
+		ids := make([]string, 0, len(piece))
+		for _, edge := range piece {
+			ids = append(ids, edge.ID)
+		}
+		if err := add("LineString", lineCoordinates(MergePolyline(piece)), map[string]interface{}{"kind": "route", "piece": i, "edges": ids}); err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			previous := pieces[i-1][len(pieces[i-1])-1]
+			gap := []pkg.Point{previous.Poly[len(previous.Poly)-1], piece[0].Poly[0]}
+			if err := add("LineString", lineCoordinates(gap), map[string]interface{}{"kind": "break", "piece": i, "from": previous.ID, "to": piece[0].ID}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for i, edge := range r.Route {
+		properties := map[string]interface{}{"kind": "edge", "index": i, "id": edge.ID, "speed": edge.Speed, "length": edge.Length}
+		addAttributes(properties, edge.Attributes)
+		if err := add("LineString", lineCoordinates(edge.Poly), properties); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, point := range r.Points {
+		properties := map[string]interface{}{"kind": "gps", "index": i, "time": point.Time.Format(time.RFC3339Nano)}
+		if err := add("Point", coordinates(point.Location), properties); err != nil {
+			return nil, err
+		}
+		if point.Snapped == nil {
+			continue
+		}
+
+		properties = map[string]interface{}{"kind": "snapped", "index": i, "edge": point.EdgeID, "distance": point.Distance}
+		addAttributes(properties, point.Attributes)
+		if err := add("Point", coordinates(*point.Snapped), properties); err != nil {
+			return nil, err
+		}
+		properties = map[string]interface{}{"kind": "link", "index": i, "edge": point.EdgeID, "distance": point.Distance}
+		if err := add("LineString", lineCoordinates([]pkg.Point{point.Location, *point.Snapped}), properties); err != nil {
+			return nil, err
+		}
+	}
+	return features, nil
+}
+
+func SaveMatchGeoJSON(results []*MatchResult, path string) error {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]GeoJSONFeature, 0)}
+	for i, result := range results {
+		features, err := result.features(i)
+		if err != nil {
+			return err
+		}
+		collection.Features = append(collection.Features, features...)
+	}
+	return SaveObject(collection, path)
+}
+
+type gpxOutput struct {
+	XMLName xml.Name         `xml:"gpx"`
+	Version string           `xml:"version,attr"`
+	Creator string           `xml:"creator,attr"`
+	Xmlns   string           `xml:"xmlns,attr"`
+	Tracks  []gpxOutputTrack `xml:"trk"`
+}
+
+type gpxOutputTrack struct {
+	Name     string             `xml:"name"`
+	Segments []gpxOutputSegment `xml:"trkseg"`
+}
+
+type gpxOutputSegment struct {
+	Points []gpxOutputPoint `xml:"trkpt"`
+}
+
+type gpxOutputPoint struct {
+	Latitude  float64 `xml:"lat,attr"`
+	Longitude float64 `xml:"lon,attr"`
+}
+
+func SaveMatchGPX(results []*MatchResult, path string) (err error) {
+	gpx := gpxOutput{Version: "1.1", Creator: "Ariadne", Xmlns: "http://www.topografix.com/GPX/1/1"}
+	for i, result := range results {
+		track := gpxOutputTrack{Name: fmt.Sprintf("trace %d", i)}
+		for _, piece := range result.Pieces() {
+			segment := gpxOutputSegment{}
+			for _, point := range MergePolyline(piece) {
+				segment.Points = append(segment.Points, gpxOutputPoint{Latitude: point.Latitude, Longitude: point.Longitude})
+			}
+			track.Segments = append(track.Segments, segment)
+		}
+		gpx.Tracks = append(gpx.Tracks, track)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+
+	if _, err := file.WriteString(xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+	return encoder.Encode(gpx)
+}
+
+func finite(value float64) interface{} {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+	return value
+}
+
+func issueFeature(kind string, points []pkg.Point, properties map[string]interface{}) (GeoJSONFeature, error) {
+	valid := slices.DeleteFunc(slices.Clone(points), func(p pkg.Point) bool {
+		return finite(p.Longitude) == nil || finite(p.Latitude) == nil
+	})
+	switch {
+	case kind == "Point" && len(valid) == 1:
+		return newFeature(kind, coordinates(valid[0]), properties)
+	case kind == "LineString" && len(valid) > 1:
+		return newFeature(kind, lineCoordinates(valid), properties)
+	}
+	return GeoJSONFeature{Type: "Feature", Properties: properties}, nil
+}
+
+func SaveValidationGeoJSON(report *pkg.ValidationReport, path string) error {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]GeoJSONFeature, 0)}
+	for _, issue := range report.Issues {
+		for _, edge := range issue.Edges {
+			properties := map[string]interface{}{"kind": issue.Kind, "id": edge.ID, "start": edge.Start, "end": edge.End, "speed": finite(edge.Speed), "length": finite(edge.Length)}
+			addAttributes(properties, edge.Attributes)
+			feature, err := issueFeature("LineString", edge.Poly, properties)
+			if err != nil {
+				return err
+			}
+			collection.Features = append(collection.Features, feature)
+		}
+		for _, node := range issue.Nodes {
+			feature, err := issueFeature("Point", []pkg.Point{node.Position}, map[string]interface{}{"kind": issue.Kind, "id": node.ID})
+			if err != nil {
+				return err
+			}
+			collection.Features = append(collection.Features, feature)
+		}
+	}
+	return SaveObject(collection, path)
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+// outputResult is a route ab, bc followed by a break to de, with one matched
+// point on each piece and one unmatched point.
+func outputResult() *MatchResult {
+	a, b, c := pkg.Point{Longitude: 0, Latitude: 0}, pkg.Point{Longitude: 0.001, Latitude: 0}, pkg.Point{Longitude: 0.002, Latitude: 0}
+	d, e := pkg.Point{Longitude: 0.003, Latitude: 0}, pkg.Point{Longitude: 0.004, Latitude: 0}
+	route := []*pkg.Edge{
+		{ID: "ab", Start: "a", End: "b", Poly: []pkg.Point{a, b}, Attributes: &pkg.Attributes{Class: "primary"}},
+		{ID: "bc", Start: "b", End: "c", Poly: []pkg.Point{b, c}},
+		{ID: "de", Start: "d", End: "e", Poly: []pkg.Point{d, e}},
+	}
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := []GPSPoint{
+		{Location: pkg.Point{Longitude: 0.0005, Latitude: 0.00001}, Time: start},
+		{Location: pkg.Point{Longitude: 0.0035, Latitude: 0.00001}, Time: start.Add(time.Minute)},
+		{Location: pkg.Point{Longitude: 1, Latitude: 1}, Time: start.Add(2 * time.Minute)},
+	}
+	return NewMatchResult(points, route, []int{0, 2, -1})
+}
+
+func TestSaveMatchGeoJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "match.geojson")
+	if err := SaveMatchGeoJSON([]*MatchResult{outputResult()}, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collection GeoJSONFeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[string]int)
+	for _, feature := range collection.Features {
+		kinds[feature.Properties["kind"].(string)]++
+		if feature.Properties["trace"] != 0.0 {
+			t.Errorf("feature %v is not tagged with trace 0", feature.Properties)
+		}
+		switch feature.Properties["kind"] {
+		case "snapped":
+			if feature.Properties["index"] == 0.0 && (feature.Properties["edge"] != "ab" || feature.Properties["class"] != "primary") {
+				t.Errorf("first snapped point %v, want edge ab with class primary", feature.Properties)
+			}
+			if feature.Properties["index"] == 1.0 && feature.Properties["edge"] != "de" {
+				t.Errorf("second snapped point %v, want edge de", feature.Properties)
+			}
+		case "break":
+			if feature.Properties["from"] != "bc" || feature.Properties["to"] != "de" {
+				t.Errorf("break %v, want bc to de", feature.Properties)
+			}
+		}
+	}
+	for kind, want := range map[string]int{"route": 2, "break": 1, "edge": 3, "gps": 3, "snapped": 2, "link": 2} {
+		if kinds[kind] != want {
+			t.Errorf("%d %s features, want %d", kinds[kind], kind, want)
+		}
+	}
+}
+
+func TestSaveMatchGPX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "match.gpx")
+	if err := SaveMatchGPX([]*MatchResult{outputResult(), outputResult()}, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gpx gpxOutput
+	if err := xml.Unmarshal(data, &gpx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gpx.Tracks) != 2 {
+		t.Fatalf("%d tracks, want one per trace (2)", len(gpx.Tracks))
+	}
+	segments := gpx.Tracks[0].Segments
+	if len(segments) != 2 || len(segments[0].Points) != 3 || len(segments[1].Points) != 2 {
+		t.Fatalf("segments %+v, want the merged ab-bc polyline and de", segments)
+	}
+	if last := segments[0].Points[2]; last.Longitude != 0.002 || last.Latitude != 0 {
+		t.Errorf("first segment ends at %+v, want c", last)
+	}
+}
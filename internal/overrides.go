@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+func DecodeOverrides(r io.Reader) ([]pkg.Override, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides []pkg.Override
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '{' {
+		var override pkg.Override
+		err = json.Unmarshal(data, &override)
+		overrides = append(overrides, override)
+	} else {
+		err = json.Unmarshal(data, &overrides)
+	}
+	return overrides, err
+}
+
+func SetOverrides(graph *pkg.Graph, overrides []pkg.Override) error {
+	for _, override := range overrides {
+		if err := override.Validate(); err != nil {
+			return fmt.Errorf("override %s: %w", override.ID, err)
+		}
+		if _, err := graph.GetEdge(override.Edge); err != nil {
+			return fmt.Errorf("override %s: %w", override.ID, err)
+		}
+	}
+	for _, override := range overrides {
+		if err := graph.SetOverride(override); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func LoadOverrides(graph *pkg.Graph, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	overrides, err := DecodeOverrides(file)
+	if err != nil {
+		return 0, err
+	}
+	return len(overrides), SetOverrides(graph, overrides)
+}
@@ -0,0 +1,417 @@
+package internal
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+const (
+	MaxBlobHeaderSize = 64 * 1024
+	MaxBlobSize       = 32 * 1024 * 1024
+)
+
+var (
+	ErrMalformedPBF       = errors.New("malformed protobuf data")
+	ErrUnsupportedBlob    = errors.New("unsupported blob compression")
+	ErrBlobTooLarge       = errors.New("blob too large")
+	errUnexpectedWireType = fmt.Errorf("%w: unexpected wire type", ErrMalformedPBF)
+)
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type protoReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *protoReader) more() bool {
+	return r.err == nil && r.pos < len(r.data)
+}
+
+func (r *protoReader) varint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.err, r.pos = ErrMalformedPBF, len(r.data)
+		return 0
+	}
+	r.pos += n
+	return value
+}
+
+func (r *protoReader) key() (int, int) {
+	key := r.varint()
+	return int(key >> 3), int(key & 7)
+}
+
+func (r *protoReader) bytes() []byte {
+	length := int(r.varint())
+	if r.err != nil || length < 0 || r.pos+length > len(r.data) {
+		r.err, r.pos = ErrMalformedPBF, len(r.data)
+		return nil
+	}
+	value := r.data[r.pos : r.pos+length]
+	r.pos += length
+	return value
+}
+
+func (r *protoReader) skip(wire int) {
+	switch wire {
+	case wireVarint:
+		r.varint()
+	case wireFixed64:
+		r.pos += 8
+	case wireBytes:
+		r.bytes()
+	case wireFixed32:
+		r.pos += 4
+	default:
+		r.err = errUnexpectedWireType
+	}
+	if r.pos > len(r.data) {
+		r.err, r.pos = ErrMalformedPBF, len(r.data)
+	}
+}
+
+func zigzag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (r *protoReader) varints(wire int) []uint64 {
+	if wire == wireVarint {
+		return []uint64{r.varint()}
+	} else if wire != wireBytes {
+		r.err = errUnexpectedWireType
+		return nil
+	}
+
+	packed := &protoReader{data: r.bytes()}
+	values := make([]uint64, 0)
+	for packed.more() {
+		values = append(values, packed.varint())
+	}
+	if packed.err != nil {
+		r.err = packed.err
+	}
+	return values
+}
+
+func deltaDecode(values []uint64) []int64 {
+	decoded, current := make([]int64, len(values)), int64(0)
+	for i, value := range values {
+		current += zigzag(value)
+		decoded[i] = current
+	}
+	return decoded
+}
+
+type pbfBlock struct {
+	Strings     []string
+	Granularity int64
+	LatOffset   int64
+	LonOffset   int64
+	Groups      [][]byte
+}
+
+func (b *pbfBlock) point(lat, lon int64) pkg.Point {
+	return pkg.Point{
+		Longitude: 1e-9 * float64(b.LonOffset+b.Granularity*lon),
+		Latitude:  1e-9 * float64(b.LatOffset+b.Granularity*lat),
+	}
+}
+
+func (b *pbfBlock) tags(keys, values []uint64) map[string]string {
+	tags := make(map[string]string, len(keys))
+	for i := 0; i < len(keys) && i < len(values); i++ {
+		if int(keys[i]) < len(b.Strings) && int(values[i]) < len(b.Strings) {
+			tags[b.Strings[keys[i]]] = b.Strings[values[i]]
+		}
+	}
+	return tags
+}
+
+func decodeBlock(data []byte) (*pbfBlock, error) {
+	block := &pbfBlock{Granularity: 100}
+	r := &protoReader{data: data}
+	for r.more() {
+		field, wire := r.key()
+		switch {
+		case field == 1 && wire == wireBytes:
+			table := &protoReader{data: r.bytes()}
+			for table.more() {
+				if field, wire := table.key(); field == 1 && wire == wireBytes {
+					block.Strings = append(block.Strings, string(table.bytes()))
+				} else {
+					table.skip(wire)
+				}
+			}
+			if table.err != nil {
+				return nil, table.err
+			}
+		case field == 2 && wire == wireBytes:
+			block.Groups = append(block.Groups, r.bytes())
+		case field == 17 && wire == wireVarint:
+			block.Granularity = int64(r.varint())
+		case field == 19 && wire == wireVarint:
+			block.LatOffset = int64(r.varint())
+		case field == 20 && wire == wireVarint:
+			block.LonOffset = int64(r.varint())
+		default:
+			r.skip(wire)
+		}
+	}
+	return block, r.err
+}
+
+func readBlob(reader io.Reader) (string, []byte, error) {
+	var size uint32
+	if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+		return "", nil, err
+	}
+	if size > MaxBlobHeaderSize {
+		return "", nil, ErrBlobTooLarge
+	}
+
+	header := make([]byte, size)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return "", nil, err
+	}
+
+	kind, dataSize := "", 0
+	r := &protoReader{data: header}
+	for r.more() {
+		field, wire := r.key()
+		switch {
+		case field == 1 && wire == wireBytes:
+			kind = string(r.bytes())
+		case field == 3 && wire == wireVarint:
+			dataSize = int(r.varint())
+		default:
+			r.skip(wire)
+		}
+	}
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	if dataSize > MaxBlobSize {
+		return "", nil, ErrBlobTooLarge
+	}
+
+	blob := make([]byte, dataSize)
+	if _, err := io.ReadFull(reader, blob); err != nil {
+		return "", nil, err
+	}
+	data, err := decodeBlob(blob)
+	return kind, data, err
+}
+
+func decodeBlob(blob []byte) ([]byte, error) {
+	r := &protoReader{data: blob}
+	for r.more() {
+		field, wire := r.key()
+		switch {
+		case field == 1 && wire == wireBytes:
+			return r.bytes(), r.err
+		case field == 3 && wire == wireBytes:
+			decompressor, err := zlib.NewReader(bytes.NewReader(r.bytes()))
+			if err != nil {
+				return nil, err
+			}
+			defer decompressor.Close()
+			return io.ReadAll(io.LimitReader(decompressor, MaxBlobSize))
+		case wire == wireBytes && field >= 4:
+			return nil, fmt.Errorf("%w: field %d", ErrUnsupportedBlob, field)
+		default:
+			r.skip(wire)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return nil, ErrMalformedPBF
+}
+
+func forEachBlock(path string, visit func(*pbfBlock) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for {
+		kind, data, err := readBlob(file)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if kind != "OSMData" {
+			continue
+		}
+
+		block, err := decodeBlock(data)
+		if err != nil {
+			return err
+		}
+		if err := visit(block); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *pbfBlock) ways(group []byte, visit func(osmWay)) error {
+	r := &protoReader{data: group}
+	for r.more() {
+		field, wire := r.key()
+		if field != 3 || wire != wireBytes {
+			r.skip(wire)
+			continue
+		}
+
+		way, keys, values := osmWay{}, []uint64(nil), []uint64(nil)
+		w := &protoReader{data: r.bytes()}
+		for w.more() {
+			field, wire := w.key()
+			switch field {
+			case 1:
+				way.ID = int64(w.varint())
+			case 2:
+				keys = w.varints(wire)
+			case 3:
+				values = w.varints(wire)
+			case 8:
+				way.Nodes = deltaDecode(w.varints(wire))
+			default:
+				w.skip(wire)
+			}
+		}
+		if w.err != nil {
+			return w.err
+		}
+
+		way.Tags = b.tags(keys, values)
+		visit(way)
+	}
+	return r.err
+}
+
+func (b *pbfBlock) nodes(group []byte, visit func(int64, pkg.Point)) error {
+	r := &protoReader{data: group}
+	for r.more() {
+		field, wire := r.key()
+		switch {
+		case field == 1 && wire == wireBytes:
+			var id, lat, lon int64
+			n := &protoReader{data: r.bytes()}
+			for n.more() {
+				field, wire := n.key()
+				switch field {
+				case 1:
+					id = zigzag(n.varint())
+				case 8:
+					lat = zigzag(n.varint())
+				case 9:
+					lon = zigzag(n.varint())
+				default:
+					n.skip(wire)
+				}
+			}
+			if n.err != nil {
+				return n.err
+			}
+			visit(id, b.point(lat, lon))
+		case field == 2 && wire == wireBytes:
+			var ids, lats, lons []int64
+			d := &protoReader{data: r.bytes()}
+			for d.more() {
+				field, wire := d.key()
+				switch field {
+				case 1:
+					ids = deltaDecode(d.varints(wire))
+				case 8:
+					lats = deltaDecode(d.varints(wire))
+				case 9:
+					lons = deltaDecode(d.varints(wire))
+				default:
+					d.skip(wire)
+				}
+			}
+			if d.err != nil {
+				return d.err
+			}
+			if len(ids) != len(lats) || len(ids) != len(lons) {
+				return fmt.Errorf("%w: dense node arrays differ in length", ErrMalformedPBF)
+			}
+			for i, id := range ids {
+				visit(id, b.point(lats[i], lons[i]))
+			}
+		default:
+			r.skip(wire)
+		}
+	}
+	return r.err
+}
+
+func readOSMPBF(path string, profile OSMProfile) (*osmData, error) {
+	data := &osmData{Nodes: make(map[int64]pkg.Point)}
+	needed := make(map[int64]bool)
+
+	err := forEachBlock(path, func(block *pbfBlock) error {
+		for _, group := range block.Groups {
+			err := block.ways(group, func(way osmWay) {
+				if !profile.Accepts(way.Tags) {
+					return
+				}
+				for _, node := range way.Nodes {
+					needed[node] = true
+				}
+				data.Ways = append(data.Ways, way)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = forEachBlock(path, func(block *pbfBlock) error {
+		for _, group := range block.Groups {
+			err := block.nodes(group, func(id int64, point pkg.Point) {
+				if needed[id] {
+					data.Nodes[id] = point
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func BuildOSMPBFNetwork(graph *pkg.Graph, path string, profile OSMProfile) error {
+	data, err := readOSMPBF(path, profile)
+	if err != nil {
+		return err
+	}
+	return buildOSMGraph(graph, data, profile)
+}
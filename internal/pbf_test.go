@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"math"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+// testdata/network.osm.pbf encodes the same nodes and ways as
+// testdata/network.osm: a residential street and a oneway primary crossing at
+// node 2, a footway, a private service road and a tertiary road, plus a node
+// no way references.
+
+func usedNodes(data *osmData) []int64 {
+	used := make([]int64, 0)
+	for _, way := range data.Ways {
+		for _, node := range way.Nodes {
+			if _, ok := data.Nodes[node]; ok && !slices.Contains(used, node) {
+				used = append(used, node)
+			}
+		}
+	}
+	slices.Sort(used)
+	return used
+}
+
+func wayIDs(data *osmData) []int64 {
+	ids := make([]int64, len(data.Ways))
+	for i, way := range data.Ways {
+		ids[i] = way.ID
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestPBFMatchesOSMXML(t *testing.T) {
+	profile, err := GetOSMProfile("car")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open("testdata/network.osm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	xmlData, err := readOSMXML(file, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbfData, err := readOSMPBF("testdata/network.osm.pbf", profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := wayIDs(pbfData), wayIDs(xmlData); !slices.Equal(got, want) || !slices.Equal(want, []int64{100, 101, 104}) {
+		t.Errorf("ways: pbf %v, xml %v, want [100 101 104]", got, want)
+	}
+	if got, want := usedNodes(pbfData), usedNodes(xmlData); !slices.Equal(got, want) || len(want) != 6 {
+		t.Errorf("nodes: pbf %v, xml %v, want 6", got, want)
+	}
+	for id, position := range pbfData.Nodes {
+		if expected := xmlData.Nodes[id]; math.Abs(position.Latitude-expected.Latitude) > 1e-7 || math.Abs(position.Longitude-expected.Longitude) > 1e-7 {
+			t.Errorf("node %d: pbf %v, xml %v", id, position, expected)
+		}
+	}
+
+	xmlGraph, pbfGraph := pkg.NewGraph(), pkg.NewGraph()
+	if err := BuildOSMNetwork(xmlGraph, "testdata/network.osm", profile); err != nil {
+		t.Fatal(err)
+	}
+	if err := BuildOSMPBFNetwork(pbfGraph, "testdata/network.osm.pbf", profile); err != nil {
+		t.Fatal(err)
+	}
+	if len(pbfGraph.Nodes) != len(xmlGraph.Nodes) || len(xmlGraph.Nodes) != 6 {
+		t.Errorf("graph nodes: pbf %d, xml %d, want 6", len(pbfGraph.Nodes), len(xmlGraph.Nodes))
+	}
+	if len(pbfGraph.Edges) != len(xmlGraph.Edges) || len(xmlGraph.Edges) != 8 {
+		t.Errorf("graph edges: pbf %d, xml %d, want 8", len(pbfGraph.Edges), len(xmlGraph.Edges))
+	}
+	for id, edge := range xmlGraph.Edges {
+		other, ok := pbfGraph.Edges[id]
+		if !ok {
+			t.Errorf("edge %s missing from the pbf import", id)
+			continue
+		}
+		if edge.Start != other.Start || edge.End != other.End || math.Abs(edge.Length-other.Length) > 1e-3 || edge.Speed != other.Speed {
+			t.Errorf("edge %s: pbf %s→%s %.3fm %.2fm/s, xml %s→%s %.3fm %.2fm/s", id, other.Start, other.End, other.Length, other.Speed, edge.Start, edge.End, edge.Length, edge.Speed)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	TimeFormatRFC3339 = "rfc3339"
+	TimeFormatEpoch   = "epoch"
+	TimeFormatEpochMS = "epoch_ms"
+)
+
+var (
+	ErrInvalidDelimiter = errors.New("invalid delimiter")
+	ErrUnknownColumn    = errors.New("unknown column")
+)
+
+type NetworkSchema struct {
+	ID            string `json:"id"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Bidirectional string `json:"bidirectional"`
+	Speed         string `json:"speed"`
+	Geometry      string `json:"geometry"`
+	Class         string `json:"class,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Lanes         string `json:"lanes,omitempty"`
+	Access        string `json:"access,omitempty"`
+	Surface       string `json:"surface,omitempty"`
+}
+
+type TraceSchema struct {
+	Date       string `json:"date,omitempty"`
+	Time       string `json:"time"`
+	Latitude   string `json:"latitude"`
+	Longitude  string `json:"longitude"`
+	TimeFormat string `json:"time_format"`
+}
+
+type Schema struct {
+	Delimiter string        `json:"delimiter,omitempty"`
+	Header    *bool         `json:"header,omitempty"`
+	Network   NetworkSchema `json:"network"`
+	Trace     TraceSchema   `json:"trace"`
+}
+
+var DefaultSchema = Schema{
+	Network: NetworkSchema{
+		ID:            "0",
+		Start:         "1",
+		End:           "2",
+		Bidirectional: "3",
+		Speed:         "4",
+		Geometry:      "6",
+	},
+	Trace: TraceSchema{
+		Date:       "0",
+		Time:       "1",
+		Latitude:   "2",
+		Longitude:  "3",
+		TimeFormat: TimeFormat,
+	},
+}
+
+func LoadSchema(path string) (Schema, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Schema{}, err
+	}
+	defer file.Close()
+
+	schema := DefaultSchema
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&schema); err != nil {
+		return Schema{}, err
+	}
+	return schema, nil
+}
+
+func (s *Schema) HasHeader() bool {
+	return s.Header == nil || *s.Header
+}
+
+func (s *Schema) delimiter(format string) (rune, error) {
+	switch strings.ToLower(s.Delimiter) {
+	case "":
+		if format == FormatCSV {
+			return ',', nil
+		}
+		return '\t', nil
+	case "tab", "\\t":
+		return '\t', nil
+	}
+
+	delimiter, size := utf8.DecodeRuneInString(s.Delimiter)
+	if size != len(s.Delimiter) || delimiter == utf8.RuneError || delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDelimiter, s.Delimiter)
+	}
+	return delimiter, nil
+}
+
+func resolveColumn(header []string, name string) (int, error) {
+	for i, column := range header {
+		if strings.EqualFold(strings.TrimSpace(column), strings.TrimSpace(name)) {
+			return i, nil
+		}
+	}
+	if index, err := strconv.Atoi(name); err == nil && index >= 0 {
+		return index, nil
+	}
+	return -1, fmt.Errorf("%w: %q", ErrUnknownColumn, name)
+}
+
+func resolveColumns(header []string, names ...string) ([]int, error) {
+	columns := make([]int, len(names))
+	for i, name := range names {
+		if name == "" {
+			columns[i] = -1
+			continue
+		}
+
+		column, err := resolveColumn(header, name)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+func requireColumns(fields map[string]string) error {
+	for _, field := range sortedKeys(fields) {
+		if strings.TrimSpace(fields[field]) == "" {
+			return &ParseError{Err: fmt.Errorf("%w: schema field %q is required", ErrMissingColumn, field)}
+		}
+	}
+	return nil
+}
+
+func optionalCell(row []string, column int) string {
+	if column < 0 || column >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[column])
+}
+
+func cell(row []string, column int) (string, error) {
+	if column < 0 || column >= len(row) {
+		return "", columnError(column, ErrMissingColumn)
+	}
+	return strings.TrimSpace(row[column]), nil
+}
+
+func ParseTime(value, format string) (time.Time, error) {
+	switch strings.ToLower(format) {
+	case TimeFormatRFC3339:
+		return time.Parse(time.RFC3339Nano, value)
+	case TimeFormatEpoch, TimeFormatEpochMS:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if strings.ToLower(format) == TimeFormatEpochMS {
+			number /= 1000
+		}
+		seconds, fraction := math.Modf(number)
+		return time.Unix(int64(seconds), int64(math.Round(fraction*1e9))).UTC(), nil
+	}
+	return time.Parse(format, value)
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+func TestResolveColumn(t *testing.T) {
+	header := []string{"ID", " start ", "geom", "7"}
+	for name, want := range map[string]int{
+		"id":     0,
+		"Start":  1,
+		" geom ": 2,
+		"2":      2,
+		"5":      5,
+		"7":      3,
+	} {
+		if got, err := resolveColumn(header, name); err != nil || got != want {
+			t.Errorf("column %q: %d (%v), want %d", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"geometry", "-1", "1.5"} {
+		if got, err := resolveColumn(header, name); !errors.Is(err, ErrUnknownColumn) {
+			t.Errorf("column %q: %d (%v), want %v", name, got, err, ErrUnknownColumn)
+		}
+	}
+	if got, err := resolveColumn(nil, "4"); err != nil || got != 4 {
+		t.Errorf("column 4 without a header: %d (%v)", got, err)
+	}
+}
+
+func renamedSchema() Schema {
+	return Schema{Delimiter: ";", Network: NetworkSchema{
+		ID: "edge", Start: "from", End: "to", Bidirectional: "two_way", Speed: "kmh", Geometry: "wkt",
+	}}
+}
+
+func TestBuildNetworkWithRenamedColumns(t *testing.T) {
+	path := writeFile(t, "network.csv", "wkt;kmh;to;from;edge;two_way\n"+
+		"LINESTRING (0 0, 0.001 0);36;b;a;ab;yes\n"+
+		"LINESTRING (0.001 0, 0.002 0);72;c;b;bc;no\n")
+	graph := pkg.NewGraph()
+	if err := BuildNetwork(graph, path, NetworkOptions{Format: FormatCSV, Schema: renamedSchema()}); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]struct {
+		start, end string
+		speed      float64
+	}{
+		"ab":                     {"a", "b", 10},
+		"ab" + pkg.ReverseSuffix: {"b", "a", 10},
+		"bc":                     {"b", "c", 20},
+	} {
+		edge := graph.Edges[id]
+		if edge == nil || edge.Start != want.start || edge.End != want.end || math.Abs(edge.Speed-want.speed) > 1e-9 {
+			t.Errorf("edge %s is %+v, want %s→%s at %v m/s", id, edge, want.start, want.end, want.speed)
+		}
+	}
+	if len(graph.Edges) != 3 {
+		t.Errorf("%d edges, want 3", len(graph.Edges))
+	}
+
+	headerless, noHeader := pkg.NewGraph(), false
+	schema := Schema{Header: &noHeader, Network: DefaultSchema.Network}
+	path = writeFile(t, "network.tsv", "ab\ta\tb\t0\t36\tMain\tLINESTRING (0 0, 0.001 0)\n")
+	if err := BuildNetwork(headerless, path, NetworkOptions{Format: FormatTSV, Schema: schema}); err != nil || headerless.Edges["ab"] == nil || len(headerless.Edges) != 1 {
+		t.Errorf("headerless network: %d edges (%v), want ab alone", len(headerless.Edges), err)
+	}
+}
+
+func TestBuildNetworkRejectsMissingColumns(t *testing.T) {
+	path := writeFile(t, "network.csv", "wkt;kmh;to;from;edge;two_way\nLINESTRING (0 0, 0.001 0);36;b;a;ab;yes\n")
+
+	schema := renamedSchema()
+	schema.Network.Geometry = " "
+	var parseError *ParseError
+	if err := BuildNetwork(pkg.NewGraph(), path, NetworkOptions{Format: FormatCSV, Schema: schema}); !errors.As(err, &parseError) || !errors.Is(err, ErrMissingColumn) || !strings.Contains(err.Error(), `"geometry"`) {
+		t.Errorf("blank geometry column: %v, want %v naming geometry", err, ErrMissingColumn)
+	}
+
+	schema = renamedSchema()
+	schema.Network.Geometry = "geometry"
+	if err := BuildNetwork(pkg.NewGraph(), path, NetworkOptions{Format: FormatCSV, Schema: schema}); !errors.Is(err, ErrUnknownColumn) || !strings.Contains(err.Error(), `"geometry"`) {
+		t.Errorf("renamed geometry column: %v, want %v naming geometry", err, ErrUnknownColumn)
+	}
+
+	schema = renamedSchema()
+	schema.Network.Class = "class"
+	if err := BuildNetwork(pkg.NewGraph(), path, NetworkOptions{Format: FormatCSV, Schema: schema}); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("optional column missing from the header: %v, want %v", err, ErrUnknownColumn)
+	}
+}
+
+func TestParseGPSDataWithRenamedColumns(t *testing.T) {
+	path := writeFile(t, "trace.csv", "recorded,lon,lat\n1700000060500,10.5,50.25\n1700000000000,10,50\n")
+	schema := Schema{Trace: TraceSchema{Time: "recorded", Latitude: "lat", Longitude: "lon", TimeFormat: TimeFormatEpochMS}}
+	points, err := ParseGPSDataWithSchema(path, FormatCSV, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].Location != (pkg.Point{Longitude: 10, Latitude: 50}) || points[1].Location != (pkg.Point{Longitude: 10.5, Latitude: 50.25}) {
+		t.Fatalf("points %+v, want both sorted by time", points)
+	}
+	if want := time.UnixMilli(1700000060500).UTC(); !points[1].Time.Equal(want) {
+		t.Errorf("time %v, want %v", points[1].Time, want)
+	}
+
+	schema.Trace.Latitude = ""
+	if _, err := ParseGPSDataWithSchema(path, FormatCSV, schema); !errors.Is(err, ErrMissingColumn) {
+		t.Errorf("blank latitude column: %v, want %v", err, ErrMissingColumn)
+	}
+	schema.Trace.Latitude = "latitude"
+	if _, err := ParseGPSDataWithSchema(path, FormatCSV, schema); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("renamed latitude column: %v, want %v", err, ErrUnknownColumn)
+	}
+
+	path = writeFile(t, "short.csv", "recorded,lon,lat\n1700000000000,10\n")
+	schema.Trace.Latitude = "lat"
+	var parseError *ParseError
+	if _, err := ParseGPSDataWithSchema(path, FormatCSV, schema); !errors.As(err, &parseError) || parseError.Row != 2 || parseError.Column != 3 || !errors.Is(err, ErrMissingColumn) {
+		t.Errorf("short row: %v, want %v on row 2, column 3", err, ErrMissingColumn)
+	}
+}
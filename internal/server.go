@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+type Server struct {
+	Graph   *pkg.Graph
+	Options MatchOptions
+	mutex   sync.Mutex
+}
+
+func NewServer(graph *pkg.Graph, options MatchOptions) *Server {
+	return &Server{Graph: graph, Options: options}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /overrides", s.listOverrides)
+	mux.HandleFunc("POST /overrides", s.setOverrides)
+	mux.HandleFunc("DELETE /overrides", s.clearOverrides)
+	mux.HandleFunc("DELETE /overrides/{id}", s.removeOverride)
+	mux.HandleFunc("POST /match", s.match)
+	mux.HandleFunc("GET /route", s.route)
+	return mux
+}
+
+func writeResponse(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeResponse(w, status, map[string]string{"error": err.Error()})
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, pkg.ErrEdgeNotFound), errors.Is(err, pkg.ErrNodeNotFound), errors.Is(err, pkg.ErrOverrideNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkg.ErrNodeNotReachable), errors.Is(err, ErrNoPathFound):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
+
+func (s *Server) listOverrides(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	writeResponse(w, http.StatusOK, s.Graph.Overrides())
+}
+
+func (s *Server) setOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides, err := DecodeOverrides(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := SetOverrides(s.Graph, overrides); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeResponse(w, http.StatusOK, s.Graph.Overrides())
+}
+
+func (s *Server) clearOverrides(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Graph.ClearOverrides()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeOverride(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.Graph.RemoveOverride(r.PathValue("id")); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) match(w http.ResponseWriter, r *http.Request) {
+	var points []GPSPoint
+	if err := json.NewDecoder(r.Body).Decode(&points); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	match, positions, err := MapMatch(s.Graph, points, s.Options)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeResponse(w, http.StatusOK, NewMatchResult(points, match, positions))
+}
+
+type routeResponse struct {
+	Departure time.Time `json:"departure"`
+	Arrival   time.Time `json:"arrival"`
+	Seconds   float64   `json:"seconds"`
+	Edges     []string  `json:"edges"`
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	departure := time.Now()
+	if value := r.URL.Query().Get("depart"); value != "" {
+		var err error
+		if departure, err = time.Parse(time.RFC3339, value); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	start, err := s.Graph.GetNode(r.URL.Query().Get("from"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	end, err := s.Graph.GetNode(r.URL.Query().Get("to"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	path, duration, err := s.Graph.FastestPath(start, end, departure)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	response := routeResponse{Departure: departure, Arrival: departure.Add(duration), Seconds: duration.Seconds(), Edges: make([]string, 0, len(path))}
+	for _, edge := range path {
+		response.Edges = append(response.Edges, edge.ID)
+	}
+	writeResponse(w, http.StatusOK, response)
+}
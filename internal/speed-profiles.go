@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+var (
+	ErrUnknownSpeedUnit = errors.New("unknown speed unit")
+)
+
+var SpeedUnits = map[string]float64{
+	"":     1000.0 / 3600.0,
+	"km/h": 1000.0 / 3600.0,
+	"mph":  MilesToKilometers * 1000.0 / 3600.0,
+	"m/s":  1,
+}
+
+type SpeedProfileFile struct {
+	SlotMinutes int                  `json:"slot_minutes"`
+	Unit        string               `json:"unit,omitempty"`
+	Edges       map[string][]float64 `json:"edges"`
+}
+
+// LoadSpeedProfiles applies the profiles in path and returns how many were
+// applied and how many named edges that are not in the graph.
+func LoadSpeedProfiles(graph *pkg.Graph, path string) (applied, unknown int, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var profiles SpeedProfileFile
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&profiles); err != nil {
+		return 0, 0, err
+	}
+
+	factor, ok := SpeedUnits[profiles.Unit]
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: %s", ErrUnknownSpeedUnit, profiles.Unit)
+	}
+
+	for id, speeds := range profiles.Edges {
+		edge, ok := graph.Edges[id]
+		if !ok {
+			unknown++
+			continue
+		}
+
+		converted := make([]float64, len(speeds))
+		for i, speed := range speeds {
+			converted[i] = speed * factor
+		}
+		profile, err := pkg.NewSpeedProfile(profiles.SlotMinutes, converted)
+		if err != nil {
+			return applied, unknown, fmt.Errorf("edge %s: %w", id, err)
+		}
+		edge.Profile = profile
+		applied++
+	}
+	return applied, unknown, nil
+}
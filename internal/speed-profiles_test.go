@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+func writeProfiles(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadSpeedProfilesCountsUnknownEdges(t *testing.T) {
+	graph := loopGraph(t)
+	path := writeProfiles(t, `{"slot_minutes": 720, "unit": "m/s", "edges": {"ab": [5, 10], "cd": [0, 20], "zz": [1, 1], "yy": [2, 2]}}`)
+
+	applied, unknown, err := LoadSpeedProfiles(graph, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 2 || unknown != 2 {
+		t.Errorf("applied %d and skipped %d unknown, want 2 and 2", applied, unknown)
+	}
+	if profile := graph.Edges["ab"].Profile; profile == nil || profile.SlotMinutes != 720 || profile.Speeds[1] != 10 {
+		t.Errorf("profile of ab %+v, want 720-minute slots of 5 and 10 m/s", profile)
+	}
+	if graph.Edges["bc"].Profile != nil {
+		t.Errorf("bc got a profile it was not given")
+	}
+}
+
+func TestLoadSpeedProfilesRejectsInvalidFiles(t *testing.T) {
+	for content, want := range map[string]error{
+		`{"slot_minutes": 60, "unit": "knots", "edges": {}}`:              ErrUnknownSpeedUnit,
+		`{"slot_minutes": 11, "edges": {"ab": [10, 10, 10]}}`:             pkg.ErrInvalidProfile,
+		`{"slot_minutes": 1440, "edges": {"ab": [-1, 1, 1, 1, 1, 1, 1]}}`: pkg.ErrInvalidProfile,
+	} {
+		if _, _, err := LoadSpeedProfiles(loopGraph(t), writeProfiles(t, content)); !errors.Is(err, want) {
+			t.Errorf("%s: error %v, want %v", content, err, want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+var (
+	ErrInvalidWKT       = errors.New("invalid WKT")
+	ErrDisjointGeometry = errors.New("disjoint multi-part geometry")
+)
+
+type wktParser struct {
+	text string
+	pos  int
+}
+
+func (p *wktParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrInvalidWKT, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *wktParser) skipSpaces() {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *wktParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+func (p *wktParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *wktParser) word() string {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.text) && (unicode.IsLetter(rune(p.text[p.pos])) || p.text[p.pos] == '_') {
+		p.pos++
+	}
+	return strings.ToUpper(p.text[start:p.pos])
+}
+
+func (p *wktParser) number() (float64, bool) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.text) && strings.IndexByte("+-.0123456789eE", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	return value, true
+}
+
+func (p *wktParser) dimensions() {
+	start := p.pos
+	switch p.word() {
+	case "Z", "M", "ZM":
+	default:
+		p.pos = start
+	}
+}
+
+func (p *wktParser) empty() bool {
+	start := p.pos
+	if p.word() == "EMPTY" {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+func (p *wktParser) point() (pkg.Point, error) {
+	longitude, ok := p.number()
+	if !ok {
+		return pkg.Point{}, p.errorf("expected longitude")
+	}
+	latitude, ok := p.number()
+	if !ok {
+		return pkg.Point{}, p.errorf("expected latitude")
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok := p.number(); !ok {
+			break
+		}
+	}
+	return pkg.Point{Longitude: longitude, Latitude: latitude}, nil
+}
+
+func (p *wktParser) lineString() (points []pkg.Point, err error) {
+	if p.empty() {
+		return nil, nil
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	for {
+		point, err := p.point()
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+
+		if p.peek() == ')' {
+			p.pos++
+			return points, nil
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *wktParser) multiLineString() (parts [][]pkg.Point, err error) {
+	if p.empty() {
+		return nil, nil
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	for {
+		part, err := p.lineString()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+
+		if p.peek() == ')' {
+			p.pos++
+			return parts, nil
+		}
+		if err := p.expect(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func ParseWKT(text string) (parts [][]pkg.Point, err error) {
+	p := &wktParser{text: text}
+	kind := p.word()
+	for _, suffix := range []string{"ZM", "Z", "M"} {
+		if kind == "LINESTRING"+suffix || kind == "MULTILINESTRING"+suffix {
+			kind = strings.TrimSuffix(kind, suffix)
+			break
+		}
+	}
+	p.dimensions()
+
+	switch kind {
+	case "LINESTRING":
+		var points []pkg.Point
+		if points, err = p.lineString(); len(points) > 0 {
+			parts = [][]pkg.Point{points}
+		}
+	case "MULTILINESTRING":
+		parts, err = p.multiLineString()
+	default:
+		p.pos = 0
+		return nil, p.errorf("unsupported geometry %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() != 0 {
+		return nil, p.errorf("unexpected trailing input")
+	}
+	return parts, nil
+}
+
+func ParseWKTLineString(text string) ([]pkg.Point, error) {
+	parts, err := ParseWKT(text)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]pkg.Point, 0)
+	for i, part := range parts {
+		if i > 0 && len(points) > 0 && len(part) > 0 && points[len(points)-1].Distance(part[0]) > pkg.EndpointTolerance {
+			return nil, fmt.Errorf("%w: part %d starts %.1fm from the end of part %d", ErrDisjointGeometry, i+1, points[len(points)-1].Distance(part[0]), i)
+		}
+		for _, point := range part {
+			if len(points) == 0 || points[len(points)-1] != point {
+				points = append(points, point)
+			}
+		}
+	}
+	if len(points) < 2 {
+		return nil, fmt.Errorf("%w: a road needs at least two distinct points", ErrInvalidWKT)
+	}
+	return points, nil
+}
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ArshiaDadras/Ariadne/pkg"
+)
+
+func line(coordinates ...float64) []pkg.Point {
+	points := make([]pkg.Point, 0, len(coordinates)/2)
+	for i := 0; i+1 < len(coordinates); i += 2 {
+		points = append(points, pkg.Point{Longitude: coordinates[i], Latitude: coordinates[i+1]})
+	}
+	return points
+}
+
+func TestParseWKT(t *testing.T) {
+	for text, want := range map[string][][]pkg.Point{
+		"LINESTRING (0 0, 1 2)":                     {line(0, 0, 1, 2)},
+		"linestring(0 0,1 2)":                       {line(0, 0, 1, 2)},
+		"LINESTRING Z (0 0 5, 1 2 6)":               {line(0, 0, 1, 2)},
+		"LINESTRINGZM (0 0 5 1, 1 2 6 2)":           {line(0, 0, 1, 2)},
+		"LINESTRING (-1.5e1 +2, 3.25 -4)":           {line(-15, 2, 3.25, -4)},
+		"MULTILINESTRING ((0 0, 1 1), (1 1, 2 2))":  {line(0, 0, 1, 1), line(1, 1, 2, 2)},
+		"MULTILINESTRING M ((0 0 1, 1 1 2), EMPTY)": {line(0, 0, 1, 1), nil},
+		"LINESTRING EMPTY":                          nil,
+		"  LINESTRING ( 0 0 , 1 2 )  ":              {line(0, 0, 1, 2)},
+	} {
+		got, err := ParseWKT(text)
+		if err != nil {
+			t.Errorf("%q: %v", text, err)
+			continue
+		}
+		if !slices.EqualFunc(got, want, func(a, b []pkg.Point) bool { return slices.Equal(a, b) }) {
+			t.Errorf("%q = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestParseWKTReportsTheOffset(t *testing.T) {
+	for _, test := range []struct {
+		text, want string
+	}{
+		{"POINT (0 0)", `offset 0: unsupported geometry "POINT"`},
+		{"LINESTRING 0 0, 1 1)", `offset 11: expected '('`},
+		{"LINESTRING (0 0, x 1)", "offset 17: expected longitude"},
+		{"LINESTRING (0 0, 1e 1)", "offset 17: expected longitude"},
+		{"LINESTRING (0 0, 1)", "offset 18: expected latitude"},
+		{"LINESTRING (0 0; 1 1)", `offset 15: expected ','`},
+		{"LINESTRING (0 0, 1 1", `offset 20: expected ','`},
+		{"LINESTRING (0 0, 1 1) x", "offset 22: unexpected trailing input"},
+		{"MULTILINESTRING ((0 0, 1 1), 2 2)", `offset 29: expected '('`},
+	} {
+		_, err := ParseWKT(test.text)
+		if !errors.Is(err, ErrInvalidWKT) || !strings.HasSuffix(err.Error(), test.want) {
+			t.Errorf("%q: %v, want %v %s", test.text, err, ErrInvalidWKT, test.want)
+		}
+	}
+}
+
+func TestParseWKTLineString(t *testing.T) {
+	points, err := ParseWKTLineString("MULTILINESTRING ((0 0, 0.001 0), (0.001 0, 0.002 0), (0.002 0, 0.002 0.001))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := line(0, 0, 0.001, 0, 0.002, 0, 0.002, 0.001); !slices.Equal(points, want) {
+		t.Errorf("joined parts %v, want %v", points, want)
+	}
+
+	for text, want := range map[string]error{
+		"MULTILINESTRING ((0 0, 0.001 0), (0.002 0, 0.003 0))": ErrDisjointGeometry,
+		"LINESTRING (0 0)":        ErrInvalidWKT,
+		"LINESTRING (0 0, 0 0)":   ErrInvalidWKT,
+		"LINESTRING EMPTY":        ErrInvalidWKT,
+		"MULTILINESTRING (EMPTY)": ErrInvalidWKT,
+	} {
+		if _, err := ParseWKTLineString(text); !errors.Is(err, want) {
+			t.Errorf("%q: %v, want %v", text, err, want)
+		}
+	}
+}
+
+func TestBuildNetworkReportsTheRowAndColumn(t *testing.T) {
+	for _, test := range []struct {
+		row     string
+		column  int
+		want    error
+		message string
+	}{
+		{"bad\ta\tb\t1\t36\tMain\tLINESTRING (0.001 0, 0.002", 7, ErrInvalidWKT, "row 3, column 7: invalid WKT at offset 26: expected latitude"},
+		{"bad\ta\tb\t1\t36\tMain\tMULTILINESTRING ((0.001 0, 0.002 0), (0.003 0, 0.004 0))", 7, ErrDisjointGeometry, "row 3, column 7: disjoint"},
+		{"bad\ta\tb\t1\tfast\tMain\tLINESTRING (0.001 0, 0.002 0)", 5, strconv.ErrSyntax, "row 3, column 5: "},
+		{"bad\ta", 7, ErrMissingColumn, "row 3, column 7: missing column"},
+	} {
+		path := writeNetwork(t, "good\ta\tb\t1\t36\tMain\tLINESTRING (0 0, 0.001 0)", test.row)
+		err := BuildNetwork(pkg.NewGraph(), path, NetworkOptions{Format: FormatTSV, Schema: DefaultSchema})
+		var parseError *ParseError
+		if !errors.As(err, &parseError) || parseError.Row != 3 || parseError.Column != test.column || !errors.Is(err, test.want) {
+			t.Errorf("%q: %v, want %v on row 3, column %d", test.row, err, test.want, test.column)
+		} else if !strings.HasPrefix(err.Error(), test.message) {
+			t.Errorf("%q: %q, want it to start with %q", test.row, err, test.message)
+		}
+	}
+
+	for err, want := range map[*ParseError]string{
+		{Row: 2, Column: 3, Err: ErrMissingColumn}: "row 2, column 3: missing column",
+		{Row: 2, Err: ErrMissingColumn}:            "row 2: missing column",
+		{Column: 3, Err: ErrMissingColumn}:         "column 3: missing column",
+		{Err: ErrMissingColumn}:                    "missing column",
+	} {
+		if err.Error() != want {
+			t.Errorf("%+v: %q, want %q", *err, err.Error(), want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"maps"
+)
+
+type Attributes struct {
+	Class   string            `json:"class,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Lanes   int               `json:"lanes,omitempty"`
+	Access  string            `json:"access,omitempty"`
+	Surface string            `json:"surface,omitempty"`
+	Tags    map[string]string `json:"tags,omitempty"`
+}
+
+func (a *Attributes) Empty() bool {
+	return a == nil || (a.Class == "" && a.Name == "" && a.Lanes == 0 && a.Access == "" && a.Surface == "" && len(a.Tags) == 0)
+}
+
+func (a *Attributes) Equal(other *Attributes) bool {
+	if a.Empty() || other.Empty() {
+		return a.Empty() == other.Empty()
+	}
+	return a.Class == other.Class && a.Name == other.Name && a.Lanes == other.Lanes &&
+		a.Access == other.Access && a.Surface == other.Surface && maps.Equal(a.Tags, other.Tags)
+}
+
+func (e *Edge) Class() string {
+	if e.Attributes == nil {
+		return ""
+	}
+	return e.Attributes.Class
+}
+
+// ClassCost scales the length of each edge by the factor of its road class,
+// copied so that later changes to factors do not reach the graph.
+func ClassCost(factors map[string]float64) func(*Edge) float64 {
+	factors = maps.Clone(factors)
+	return func(edge *Edge) float64 {
+		if factor, ok := factors[edge.Class()]; ok {
+			return edge.Length * factor
+		}
+		return edge.Length
+	}
+}
@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClassCostChangesTheRoute(t *testing.T) {
+	g := overrideGraph(t)
+	if got := pathIDs(t, g, "a", "c"); !slices.Equal(got, []string{"ab", "bc"}) {
+		t.Fatalf("path without class costs %v, want [ab bc]", got)
+	}
+
+	g.Edges["ab"].Attributes = &Attributes{Class: "residential"}
+	factors := map[string]float64{"residential": 3}
+	g.SetCost(ClassCost(factors))
+	if got := pathIDs(t, g, "a", "c"); !slices.Equal(got, []string{"ad", "dc"}) {
+		t.Errorf("path avoiding residential roads %v, want [ad dc]", got)
+	}
+	if got, want := g.EdgeCost(g.Edges["ab"]), 3*g.Edges["ab"].Length; got != want {
+		t.Errorf("cost of the residential edge %v, want %v", got, want)
+	}
+
+	factors["residential"] = 1
+	if got, want := g.EdgeCost(g.Edges["ab"]), 3*g.Edges["ab"].Length; got != want {
+		t.Errorf("changing the factors after SetCost made the cost %v, want %v", got, want)
+	}
+}
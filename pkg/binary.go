@@ -0,0 +1,584 @@
+package pkg
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+	"unsafe"
+)
+
+const (
+	BinaryMagic   = "ARDNGRPH"
+	BinaryVersion = 2
+)
+
+const (
+	binaryHeaderSize     = 80
+	binaryPointSize      = 16
+	binaryNodeSize       = 24
+	binaryEdgeSize       = 56
+	binaryTreeNodeSize   = 48
+	binaryTreeEntrySize  = 40
+	binaryLeafFlag       = 1
+	binarySectionAlign   = 8
+	binaryChecksumOffset = 64
+)
+
+const (
+	extraIsolated = 1 << iota
+	extraAttributes
+	extraProfile
+)
+
+var (
+	ErrInvalidBinary    = errors.New("invalid binary graph")
+	ErrBinaryVersion    = errors.New("unsupported binary graph version")
+	ErrChecksumMismatch = errors.New("binary graph checksum mismatch")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+type binaryHeader struct {
+	Nodes       uint64
+	Edges       uint64
+	Points      uint64
+	TreeNodes   uint64
+	TreeEntries uint64
+	Strings     uint64
+	Checksum    uint32
+}
+
+type binaryLayout struct {
+	points, nodes, edges, treeNodes, treeEntries, strings, end uint64
+}
+
+func align(n uint64) uint64 {
+	return (n + binarySectionAlign - 1) / binarySectionAlign * binarySectionAlign
+}
+
+func (h *binaryHeader) layout() binaryLayout {
+	var l binaryLayout
+	l.points = binaryHeaderSize
+	l.nodes = align(l.points + h.Points*binaryPointSize)
+	l.edges = align(l.nodes + h.Nodes*binaryNodeSize)
+	l.treeNodes = align(l.edges + h.Edges*binaryEdgeSize)
+	l.treeEntries = align(l.treeNodes + h.TreeNodes*binaryTreeNodeSize)
+	l.strings = align(l.treeEntries + h.TreeEntries*binaryTreeEntrySize)
+	l.end = l.strings + h.Strings
+	return l
+}
+
+type binaryWriter struct {
+	buffer []byte
+}
+
+func (w *binaryWriter) u32(v uint32) {
+	w.buffer = binary.LittleEndian.AppendUint32(w.buffer, v)
+}
+
+func (w *binaryWriter) u64(v uint64) {
+	w.buffer = binary.LittleEndian.AppendUint64(w.buffer, v)
+}
+
+func (w *binaryWriter) f64(v float64) {
+	w.u64(math.Float64bits(v))
+}
+
+func (w *binaryWriter) text(s string) {
+	w.u32(uint32(len(s)))
+	w.buffer = append(w.buffer, s...)
+}
+
+func (w *binaryWriter) box(b BoundingBox) {
+	w.f64(b.MinLongitude)
+	w.f64(b.MinLatitude)
+	w.f64(b.MaxLongitude)
+	w.f64(b.MaxLatitude)
+}
+
+func (w *binaryWriter) pad(offset uint64) {
+	for uint64(len(w.buffer)) < offset {
+		w.buffer = append(w.buffer, 0)
+	}
+}
+
+// encodeExtra packs the optional edge fields; edges without any are stored
+// with an empty extra and skipped when reading.
+func encodeExtra(edge *Edge) []byte {
+	flags := uint32(0)
+	if edge.Isolated {
+		flags |= extraIsolated
+	}
+	if !edge.Attributes.Empty() {
+		flags |= extraAttributes
+	}
+	if edge.Profile != nil {
+		flags |= extraProfile
+	}
+	if flags == 0 && edge.OSMWayID == 0 && len(edge.OSMNodeIDs) == 0 {
+		return nil
+	}
+
+	w := &binaryWriter{}
+	w.u32(flags)
+	w.u64(uint64(edge.OSMWayID))
+	w.u32(uint32(len(edge.OSMNodeIDs)))
+	for _, id := range edge.OSMNodeIDs {
+		w.u64(uint64(id))
+	}
+	if flags&extraAttributes != 0 {
+		attributes := edge.Attributes
+		w.text(attributes.Class)
+		w.text(attributes.Name)
+		w.text(attributes.Access)
+		w.text(attributes.Surface)
+		w.u32(uint32(int32(attributes.Lanes)))
+		keys := make([]string, 0, len(attributes.Tags))
+		for key := range attributes.Tags {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		w.u32(uint32(len(keys)))
+		for _, key := range keys {
+			w.text(key)
+			w.text(attributes.Tags[key])
+		}
+	}
+	if flags&extraProfile != 0 {
+		w.u32(uint32(edge.Profile.SlotMinutes))
+		w.u32(uint32(len(edge.Profile.Speeds)))
+		for _, speed := range edge.Profile.Speeds {
+			w.f64(speed)
+		}
+	}
+	return w.buffer
+}
+
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) next(n uint64) []byte {
+	if r.err == nil && n > uint64(len(r.data)) {
+		r.err = fmt.Errorf("%w: edge extra out of range", ErrInvalidBinary)
+	}
+	if r.err != nil {
+		return make([]byte, 8)
+	}
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *binaryReader) u32() uint32 {
+	return binary.LittleEndian.Uint32(r.next(4))
+}
+
+func (r *binaryReader) u64() uint64 {
+	return binary.LittleEndian.Uint64(r.next(8))
+}
+
+func (r *binaryReader) f64() float64 {
+	return math.Float64frombits(r.u64())
+}
+
+func (r *binaryReader) text() string {
+	value := r.next(uint64(r.u32()))
+	if r.err != nil {
+		return ""
+	}
+	return string(value)
+}
+
+func decodeExtra(edge *Edge, data []byte) error {
+	r := &binaryReader{data: data}
+	flags := r.u32()
+	edge.Isolated = flags&extraIsolated != 0
+	edge.OSMWayID = int64(r.u64())
+	if count := uint64(r.u32()); count > 0 && r.err == nil {
+		if count > uint64(len(r.data))/8 {
+			return fmt.Errorf("%w: edge extra out of range", ErrInvalidBinary)
+		}
+		edge.OSMNodeIDs = make([]int64, count)
+		for i := range edge.OSMNodeIDs {
+			edge.OSMNodeIDs[i] = int64(r.u64())
+		}
+	}
+	if flags&extraAttributes != 0 {
+		edge.Attributes = &Attributes{Class: r.text(), Name: r.text(), Access: r.text(), Surface: r.text()}
+		edge.Attributes.Lanes = int(int32(r.u32()))
+		if count := r.u32(); count > 0 && r.err == nil {
+			edge.Attributes.Tags = make(map[string]string)
+			for i := uint32(0); i < count && r.err == nil; i++ {
+				key := r.text()
+				edge.Attributes.Tags[key] = r.text()
+			}
+		}
+	}
+	if flags&extraProfile != 0 {
+		slotMinutes, count := int(r.u32()), uint64(r.u32())
+		if r.err == nil && count > uint64(len(r.data))/8 {
+			return fmt.Errorf("%w: edge extra out of range", ErrInvalidBinary)
+		}
+		speeds := make([]float64, count)
+		for i := range speeds {
+			speeds[i] = r.f64()
+		}
+		if r.err != nil {
+			return r.err
+		}
+		profile, err := NewSpeedProfile(slotMinutes, speeds)
+		if err != nil {
+			return err
+		}
+		edge.Profile = profile
+	}
+	return r.err
+}
+
+type stringTable struct {
+	data []byte
+}
+
+func (t *stringTable) add(s string) (uint32, uint32) {
+	offset := uint32(len(t.data))
+	t.data = append(t.data, s...)
+	return offset, uint32(len(s))
+}
+
+func flattenTree(root *rtreeNode) (nodes []*rtreeNode, entries []*rtreeEntry) {
+	nodes = []*rtreeNode{root}
+	for i := 0; i < len(nodes); i++ {
+		if nodes[i].isLeaf() {
+			entries = append(entries, nodes[i].Entries...)
+		} else {
+			nodes = append(nodes, nodes[i].Children...)
+		}
+	}
+	return
+}
+
+func (g *Graph) WriteBinary(w io.Writer) error {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes = append(nodes, node)
+	}
+	slices.SortFunc(nodes, func(a, b *Node) int { return cmp.Compare(a.ID, b.ID) })
+	nodeIndex := make(map[string]uint32, len(nodes))
+	for i, node := range nodes {
+		nodeIndex[node.ID] = uint32(i)
+	}
+
+	edges := make([]*Edge, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		edges = append(edges, edge)
+	}
+	slices.SortFunc(edges, func(a, b *Edge) int { return cmp.Compare(a.ID, b.ID) })
+	edgeIndex := make(map[*Edge]uint32, len(edges))
+	for i, edge := range edges {
+		edgeIndex[edge] = uint32(i)
+	}
+
+	tree, ok := g.Index.(*RTree)
+	if !ok {
+		tree = NewRTree(slices.DeleteFunc(slices.Clone(edges), func(e *Edge) bool { return e.Isolated }))
+	}
+	treeNodes, treeEntries := flattenTree(tree.Root)
+
+	header := binaryHeader{
+		Nodes:       uint64(len(nodes)),
+		Edges:       uint64(len(edges)),
+		TreeNodes:   uint64(len(treeNodes)),
+		TreeEntries: uint64(len(treeEntries)),
+	}
+	for _, edge := range edges {
+		header.Points += uint64(len(edge.Poly))
+	}
+
+	strings := &stringTable{}
+	out := &binaryWriter{buffer: make([]byte, binaryHeaderSize)}
+	for _, edge := range edges {
+		for _, point := range edge.Poly {
+			out.f64(point.Longitude)
+			out.f64(point.Latitude)
+		}
+	}
+
+	out.pad(header.layout().nodes)
+	for _, node := range nodes {
+		offset, length := strings.add(node.ID)
+		out.u32(offset)
+		out.u32(length)
+		out.f64(node.Position.Longitude)
+		out.f64(node.Position.Latitude)
+	}
+
+	out.pad(header.layout().edges)
+	pointOffset := uint64(0)
+	for _, edge := range edges {
+		start, ok := nodeIndex[edge.Start]
+		if !ok {
+			return fmt.Errorf("edge %s: %w", edge.ID, ErrNodeNotFound)
+		}
+		end, ok := nodeIndex[edge.End]
+		if !ok {
+			return fmt.Errorf("edge %s: %w", edge.ID, ErrNodeNotFound)
+		}
+
+		idOffset, idLength := strings.add(edge.ID)
+		extraOffset, extraLength := strings.add(string(encodeExtra(edge)))
+		out.u32(idOffset)
+		out.u32(idLength)
+		out.u32(start)
+		out.u32(end)
+		out.f64(edge.Speed)
+		out.f64(edge.Length)
+		out.u64(pointOffset)
+		out.u64(uint64(len(edge.Poly)))
+		out.u32(extraOffset)
+		out.u32(extraLength)
+		pointOffset += uint64(len(edge.Poly))
+	}
+
+	out.pad(header.layout().treeNodes)
+	next, entry := uint32(1), uint32(0)
+	for _, node := range treeNodes {
+		out.box(node.Box)
+		if node.isLeaf() {
+			out.u32(entry)
+			out.u32(uint32(len(node.Entries)))
+			out.u32(binaryLeafFlag)
+			entry += uint32(len(node.Entries))
+		} else {
+			out.u32(next)
+			out.u32(uint32(len(node.Children)))
+			out.u32(0)
+			next += uint32(len(node.Children))
+		}
+		out.u32(0)
+	}
+
+	out.pad(header.layout().treeEntries)
+	for _, entry := range treeEntries {
+		index, ok := edgeIndex[entry.Edge]
+		if !ok {
+			return fmt.Errorf("index entry for edge %s: %w", entry.Edge.ID, ErrEdgeNotFound)
+		}
+		out.box(entry.Box)
+		out.u32(index)
+		out.u32(uint32(entry.Index))
+	}
+
+	out.pad(header.layout().strings)
+	header.Strings = uint64(len(strings.data))
+	out.buffer = append(out.buffer, strings.data...)
+	header.Checksum = crc32.Checksum(out.buffer[binaryHeaderSize:], castagnoli)
+
+	head := &binaryWriter{}
+	head.buffer = append(head.buffer, BinaryMagic...)
+	head.u32(BinaryVersion)
+	head.u32(0)
+	head.u64(header.Nodes)
+	head.u64(header.Edges)
+	head.u64(header.Points)
+	head.u64(header.TreeNodes)
+	head.u64(header.TreeEntries)
+	head.u64(header.Strings)
+	head.u32(header.Checksum)
+	head.pad(binaryHeaderSize)
+	copy(out.buffer, head.buffer)
+
+	writer := bufio.NewWriter(w)
+	if _, err := writer.Write(out.buffer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func readHeader(data []byte) (binaryHeader, error) {
+	if len(data) < binaryHeaderSize || string(data[:len(BinaryMagic)]) != BinaryMagic {
+		return binaryHeader{}, ErrInvalidBinary
+	}
+	if version := binary.LittleEndian.Uint32(data[8:]); version != BinaryVersion {
+		return binaryHeader{}, fmt.Errorf("%w: %d", ErrBinaryVersion, version)
+	}
+
+	header := binaryHeader{
+		Nodes:       binary.LittleEndian.Uint64(data[16:]),
+		Edges:       binary.LittleEndian.Uint64(data[24:]),
+		Points:      binary.LittleEndian.Uint64(data[32:]),
+		TreeNodes:   binary.LittleEndian.Uint64(data[40:]),
+		TreeEntries: binary.LittleEndian.Uint64(data[48:]),
+		Strings:     binary.LittleEndian.Uint64(data[56:]),
+		Checksum:    binary.LittleEndian.Uint32(data[binaryChecksumOffset:]),
+	}
+	if header.layout().end != uint64(len(data)) {
+		return binaryHeader{}, fmt.Errorf("%w: size mismatch", ErrInvalidBinary)
+	}
+	return header, nil
+}
+
+func isLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
+
+func readPoints(data []byte, count uint64) []Point {
+	if count == 0 {
+		return nil
+	}
+	points := make([]Point, count)
+	if isLittleEndian() && uintptr(unsafe.Pointer(&data[0]))%unsafe.Alignof(float64(0)) == 0 {
+		copy(points, unsafe.Slice((*Point)(unsafe.Pointer(&data[0])), count))
+		return points
+	}
+
+	for i := range points {
+		points[i] = Point{
+			Longitude: math.Float64frombits(binary.LittleEndian.Uint64(data[i*binaryPointSize:])),
+			Latitude:  math.Float64frombits(binary.LittleEndian.Uint64(data[i*binaryPointSize+8:])),
+		}
+	}
+	return points
+}
+
+func readBox(data []byte) BoundingBox {
+	return BoundingBox{
+		MinLongitude: math.Float64frombits(binary.LittleEndian.Uint64(data[0:])),
+		MinLatitude:  math.Float64frombits(binary.LittleEndian.Uint64(data[8:])),
+		MaxLongitude: math.Float64frombits(binary.LittleEndian.Uint64(data[16:])),
+		MaxLatitude:  math.Float64frombits(binary.LittleEndian.Uint64(data[24:])),
+	}
+}
+
+func readString(table []byte, record []byte) (string, error) {
+	offset, length := uint64(binary.LittleEndian.Uint32(record)), uint64(binary.LittleEndian.Uint32(record[4:]))
+	if offset+length > uint64(len(table)) {
+		return "", fmt.Errorf("%w: string out of range", ErrInvalidBinary)
+	}
+	return string(table[offset : offset+length]), nil
+}
+
+// ReadBinaryGraph decodes a graph written by WriteBinary. The graph is copied
+// into Go memory, so data may be released once it returns.
+func ReadBinaryGraph(data []byte, verify bool) (*Graph, error) {
+	header, err := readHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if verify && crc32.Checksum(data[binaryHeaderSize:], castagnoli) != header.Checksum {
+		return nil, ErrChecksumMismatch
+	}
+
+	layout := header.layout()
+	table := data[layout.strings:layout.end]
+	points := readPoints(data[layout.points:], header.Points)
+
+	graph := NewGraph()
+	nodes := make([]*Node, header.Nodes)
+	for i := range nodes {
+		record := data[layout.nodes+uint64(i)*binaryNodeSize:]
+		id, err := readString(table, record)
+		if err != nil {
+			return nil, err
+		}
+		position := Point{
+			Longitude: math.Float64frombits(binary.LittleEndian.Uint64(record[8:])),
+			Latitude:  math.Float64frombits(binary.LittleEndian.Uint64(record[16:])),
+		}
+		if nodes[i], err = graph.AddNode(id, position); err != nil {
+			return nil, fmt.Errorf("node %s: %w", id, err)
+		}
+	}
+
+	edges := make([]*Edge, header.Edges)
+	for i := range edges {
+		record := data[layout.edges+uint64(i)*binaryEdgeSize:]
+		id, err := readString(table, record)
+		if err != nil {
+			return nil, err
+		}
+		start, end := uint64(binary.LittleEndian.Uint32(record[8:])), uint64(binary.LittleEndian.Uint32(record[12:]))
+		offset, count := binary.LittleEndian.Uint64(record[32:]), binary.LittleEndian.Uint64(record[40:])
+		if start >= header.Nodes || end >= header.Nodes || offset+count > header.Points {
+			return nil, fmt.Errorf("%w: edge %s out of range", ErrInvalidBinary, id)
+		}
+
+		edges[i] = &Edge{
+			ID:     id,
+			Start:  nodes[start].ID,
+			End:    nodes[end].ID,
+			Speed:  math.Float64frombits(binary.LittleEndian.Uint64(record[16:])),
+			Length: math.Float64frombits(binary.LittleEndian.Uint64(record[24:])),
+			Poly:   points[offset : offset+count : offset+count],
+		}
+
+		if extraOffset, extraLength := uint64(binary.LittleEndian.Uint32(record[48:])), uint64(binary.LittleEndian.Uint32(record[52:])); extraLength > 0 {
+			if extraOffset+extraLength > uint64(len(table)) {
+				return nil, fmt.Errorf("%w: edge %s extra out of range", ErrInvalidBinary, id)
+			}
+			if err := decodeExtra(edges[i], table[extraOffset:extraOffset+extraLength]); err != nil {
+				return nil, fmt.Errorf("edge %s: %w", id, err)
+			}
+		}
+
+		if err := graph.InsertEdge(edges[i]); err != nil {
+			return nil, fmt.Errorf("edge %s: %w", id, err)
+		}
+	}
+
+	tree, err := readTree(data, header, layout, edges)
+	if err != nil {
+		return nil, err
+	}
+	graph.Index = tree
+	return graph, nil
+}
+
+func readTree(data []byte, header binaryHeader, layout binaryLayout, edges []*Edge) (*RTree, error) {
+	entries, indexed := make([]*rtreeEntry, header.TreeEntries), make(map[*Edge]bool)
+	for i := range entries {
+		record := data[layout.treeEntries+uint64(i)*binaryTreeEntrySize:]
+		edge, segment := uint64(binary.LittleEndian.Uint32(record[32:])), int(binary.LittleEndian.Uint32(record[36:]))
+		if edge >= header.Edges || segment+1 >= len(edges[edge].Poly) {
+			return nil, fmt.Errorf("%w: index entry out of range", ErrInvalidBinary)
+		}
+		entries[i] = &rtreeEntry{Box: readBox(record), Edge: edges[edge], Index: segment}
+		indexed[edges[edge]] = true
+	}
+
+	nodes := make([]*rtreeNode, header.TreeNodes)
+	for i := range nodes {
+		nodes[i] = &rtreeNode{}
+	}
+	for i, node := range nodes {
+		record := data[layout.treeNodes+uint64(i)*binaryTreeNodeSize:]
+		first, count := uint64(binary.LittleEndian.Uint32(record[32:])), uint64(binary.LittleEndian.Uint32(record[36:]))
+		node.Box = readBox(record)
+
+		if binary.LittleEndian.Uint32(record[40:])&binaryLeafFlag != 0 {
+			if first+count > header.TreeEntries {
+				return nil, fmt.Errorf("%w: index leaf out of range", ErrInvalidBinary)
+			}
+			node.Entries = entries[first : first+count : first+count]
+		} else {
+			if first+count > header.TreeNodes || first <= uint64(i) {
+				return nil, fmt.Errorf("%w: index node out of range", ErrInvalidBinary)
+			}
+			node.Children = nodes[first : first+count : first+count]
+		}
+	}
+
+	tree := &RTree{Root: &rtreeNode{}, Size: len(indexed)}
+	if len(nodes) > 0 {
+		tree.Root = nodes[0]
+	}
+	return tree, nil
+}
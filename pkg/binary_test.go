@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func binaryGraph(t *testing.T) *Graph {
+	t.Helper()
+	g := NewGraph()
+	a, _ := g.AddNode("a", Point{Longitude: 10, Latitude: 50})
+	b, _ := g.AddNode("b", Point{Longitude: 10.002, Latitude: 50})
+	c, _ := g.AddNode("c", Point{Longitude: 10.002, Latitude: 50.002})
+
+	profile, err := NewSpeedProfile(60, []float64{5, 10, 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, edge := range []*Edge{
+		NewEdge("ab", a, b, 10, []Point{a.Position, {Longitude: 10.001, Latitude: 50.0005}, b.Position}),
+		NewEdge("ab_parallel", a, b, 8, []Point{a.Position, b.Position}),
+		NewEdge("bc", b, c, 12, []Point{b.Position, c.Position}),
+		NewEdge("ca", c, a, 5, []Point{c.Position, a.Position}),
+	} {
+		if err := g.InsertEdge(edge); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.Edges["ab"].OSMWayID, g.Edges["ab"].OSMNodeIDs = 42, []int64{1, 7, 2}
+	g.Edges["ab"].Attributes = &Attributes{Class: "primary", Name: "Main", Lanes: 2, Surface: "asphalt", Tags: map[string]string{"ref": "B1", "lit": "yes"}}
+	g.Edges["ab"].Profile = profile
+	g.Edges["bc"].Attributes = &Attributes{Access: "private"}
+	g.Edges["ca"].Isolated = true
+	g.Index = NewRTree([]*Edge{g.Edges["ab"], g.Edges["ab_parallel"], g.Edges["bc"]})
+	return g
+}
+
+func encodeGraph(t *testing.T, g *Graph) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := g.WriteBinary(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestBinaryGraphRoundTrip(t *testing.T) {
+	original := binaryGraph(t)
+	data := encodeGraph(t, original)
+	loaded, err := ReadBinaryGraph(data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Nodes) != len(original.Nodes) || len(loaded.Edges) != len(original.Edges) {
+		t.Fatalf("%d nodes and %d edges, want %d and %d", len(loaded.Nodes), len(loaded.Edges), len(original.Nodes), len(original.Edges))
+	}
+	for id, node := range original.Nodes {
+		if got := loaded.Nodes[id]; got == nil || got.Position != node.Position {
+			t.Errorf("node %s: %+v, want %+v", id, got, node)
+		}
+	}
+	for id, edge := range original.Edges {
+		got := loaded.Edges[id]
+		if got == nil {
+			t.Fatalf("edge %s missing", id)
+		}
+		if got.Start != edge.Start || got.End != edge.End || got.Speed != edge.Speed || got.Length != edge.Length || !slices.Equal(got.Poly, edge.Poly) {
+			t.Errorf("edge %s: %+v, want %+v", id, got, edge)
+		}
+		if got.OSMWayID != edge.OSMWayID || !slices.Equal(got.OSMNodeIDs, edge.OSMNodeIDs) || got.Isolated != edge.Isolated {
+			t.Errorf("edge %s: osm %d %v isolated %v, want %d %v %v", id, got.OSMWayID, got.OSMNodeIDs, got.Isolated, edge.OSMWayID, edge.OSMNodeIDs, edge.Isolated)
+		}
+		if !got.Attributes.Equal(edge.Attributes) || !got.Profile.Equal(edge.Profile) {
+			t.Errorf("edge %s: attributes %+v profile %+v, want %+v %+v", id, got.Attributes, got.Profile, edge.Attributes, edge.Profile)
+		}
+	}
+
+	a, b := loaded.Nodes["a"], loaded.Nodes["b"]
+	if got := len(a.OutEdges[b]); got != 2 {
+		t.Errorf("%d parallel edges from a to b, want 2", got)
+	}
+	if got := len(b.InEdges[a]); got != 2 {
+		t.Errorf("%d parallel edges into b from a, want 2", got)
+	}
+
+	if got, want := loaded.Index.(*RTree).Size, original.Index.(*RTree).Size; got != want || want != 3 {
+		t.Errorf("loaded index holds %d edges, want %d (3)", got, want)
+	}
+	for _, point := range []Point{{Longitude: 10.001, Latitude: 50.0004}, {Longitude: 10.0021, Latitude: 50.001}, {Longitude: 10.001, Latitude: 50.0015}} {
+		want, got := candidateIDs(original.Index.Nearest(point, 4)), candidateIDs(loaded.Index.Nearest(point, 4))
+		if !slices.Equal(got, want) {
+			t.Errorf("nearest %v: %v, want %v", point, got, want)
+		}
+		if slices.Contains(got, "ca") {
+			t.Errorf("nearest %v returned the isolated edge", point)
+		}
+	}
+
+	loaded.Edges["ab"].Poly[1] = Point{}
+	if original.Edges["ab"].Poly[1] == (Point{}) {
+		t.Error("loaded polylines alias the original graph")
+	}
+}
+
+func TestBinaryGraphSkipsEmptyExtras(t *testing.T) {
+	g := NewGraph()
+	a, _ := g.AddNode("a", Point{})
+	b, _ := g.AddNode("b", Point{Longitude: 0.001})
+	if _, err := g.AddEdge("ab", a, b, 10, []Point{a.Position, b.Position}); err != nil {
+		t.Fatal(err)
+	}
+	data := encodeGraph(t, g)
+	header, err := readHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length := binary.LittleEndian.Uint32(data[header.layout().edges+52:]); length != 0 {
+		t.Errorf("edge without extras stored %d extra bytes", length)
+	}
+}
+
+func TestReadBinaryGraphRejectsCorruptData(t *testing.T) {
+	data := encodeGraph(t, binaryGraph(t))
+
+	corrupted := slices.Clone(data)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if _, err := ReadBinaryGraph(corrupted, true); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("corrupted checksum: %v, want %v", err, ErrChecksumMismatch)
+	}
+	if _, err := ReadBinaryGraph(corrupted, false); errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("corrupted data without verification: %v", err)
+	}
+
+	version := slices.Clone(data)
+	binary.LittleEndian.PutUint32(version[8:], BinaryVersion+1)
+	if _, err := ReadBinaryGraph(version, false); !errors.Is(err, ErrBinaryVersion) {
+		t.Errorf("wrong version: %v, want %v", err, ErrBinaryVersion)
+	}
+
+	for _, size := range []int{0, binaryHeaderSize - 1, binaryHeaderSize, len(data) - 1} {
+		if _, err := ReadBinaryGraph(data[:size], false); !errors.Is(err, ErrInvalidBinary) {
+			t.Errorf("truncated to %d bytes: %v, want %v", size, err, ErrInvalidBinary)
+		}
+	}
+
+	extra := encodeExtra(binaryGraph(t).Edges["ab"])
+	for size := range extra {
+		if err := decodeExtra(&Edge{}, extra[:size]); !errors.Is(err, ErrInvalidBinary) {
+			t.Fatalf("extra truncated to %d bytes: %v, want %v", size, err, ErrInvalidBinary)
+		}
+	}
+}
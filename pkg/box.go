@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"math"
+)
+
+type BoundingBox struct {
+	MinLongitude float64 `json:"min_longitude"`
+	MinLatitude  float64 `json:"min_latitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+}
+
+func NewBoundingBox(a, b Point) BoundingBox {
+	return BoundingBox{
+		MinLongitude: math.Min(a.Longitude, b.Longitude),
+		MinLatitude:  math.Min(a.Latitude, b.Latitude),
+		MaxLongitude: math.Max(a.Longitude, b.Longitude),
+		MaxLatitude:  math.Max(a.Latitude, b.Latitude),
+	}
+}
+
+func (b BoundingBox) Union(other BoundingBox) BoundingBox {
+	return BoundingBox{
+		MinLongitude: math.Min(b.MinLongitude, other.MinLongitude),
+		MinLatitude:  math.Min(b.MinLatitude, other.MinLatitude),
+		MaxLongitude: math.Max(b.MaxLongitude, other.MaxLongitude),
+		MaxLatitude:  math.Max(b.MaxLatitude, other.MaxLatitude),
+	}
+}
+
+func (b BoundingBox) Intersects(other BoundingBox) bool {
+	return b.MinLongitude <= other.MaxLongitude && other.MinLongitude <= b.MaxLongitude &&
+		b.MinLatitude <= other.MaxLatitude && other.MinLatitude <= b.MaxLatitude
+}
+
+func (b BoundingBox) Contains(point Point) bool {
+	return b.MinLongitude <= point.Longitude && point.Longitude <= b.MaxLongitude &&
+		b.MinLatitude <= point.Latitude && point.Latitude <= b.MaxLatitude
+}
+
+func (b BoundingBox) Distance(point Point) float64 {
+	if b.MinLongitude <= point.Longitude && point.Longitude <= b.MaxLongitude {
+		return point.Distance(Point{
+			Longitude: point.Longitude,
+			Latitude:  clamp(point.Latitude, b.MinLatitude, b.MaxLatitude),
+		})
+	}
+
+	longitude := b.MinLongitude
+	if math.Abs(longitudeDifference(point.Longitude, b.MaxLongitude)) < math.Abs(longitudeDifference(point.Longitude, b.MinLongitude)) {
+		longitude = b.MaxLongitude
+	}
+
+	lat, dlong := toRadians(point.Latitude), toRadians(longitudeDifference(point.Longitude, longitude))
+	closest := toDegrees(math.Atan2(math.Sin(lat), math.Cos(lat)*math.Cos(dlong)))
+	return point.Distance(Point{
+		Longitude: longitude,
+		Latitude:  clamp(closest, b.MinLatitude, b.MaxLatitude),
+	})
+}
+
+func NewArcBoundingBoxes(a, b Point) []BoundingBox {
+	box := NewBoundingBox(a, b)
+	for _, vertex := range arcVertices(a, b) {
+		box.MinLatitude, box.MaxLatitude = math.Min(box.MinLatitude, vertex), math.Max(box.MaxLatitude, vertex)
+	}
+
+	if box.MaxLatitude >= 90-Epsilon || box.MinLatitude <= -90+Epsilon {
+		box.MinLongitude, box.MaxLongitude = -180, 180
+	} else if box.MaxLongitude-box.MinLongitude > 180 {
+		return []BoundingBox{
+			{MinLongitude: box.MaxLongitude, MinLatitude: box.MinLatitude, MaxLongitude: 180, MaxLatitude: box.MaxLatitude},
+			{MinLongitude: -180, MinLatitude: box.MinLatitude, MaxLongitude: box.MinLongitude, MaxLatitude: box.MaxLatitude},
+		}
+	}
+	return []BoundingBox{box}
+}
+
+func arcVertices(a, b Point) (latitudes []float64) {
+	xA, yA, zA := a.vector()
+	xB, yB, zB := b.vector()
+	nx, ny, nz := yA*zB-zA*yB, zA*xB-xA*zB, xA*yB-yA*xB
+	norm := math.Sqrt(nx*nx + ny*ny + nz*nz)
+	if norm < Epsilon {
+		return
+	}
+	nx, ny, nz = nx/norm, ny/norm, nz/norm
+
+	vx, vy, vz := -nz*nx, -nz*ny, 1-nz*nz
+	if length := math.Sqrt(vx*vx + vy*vy + vz*vz); length > Epsilon {
+		vx, vy, vz = vx/length, vy/length, vz/length
+	} else {
+		return
+	}
+
+	for _, sign := range []float64{1, -1} {
+		x, y, z := sign*vx, sign*vy, sign*vz
+		before := (yA*z-zA*y)*nx + (zA*x-xA*z)*ny + (xA*y-yA*x)*nz
+		after := (y*zB-z*yB)*nx + (z*xB-x*zB)*ny + (x*yB-y*xB)*nz
+		if before >= 0 && after >= 0 {
+			latitudes = append(latitudes, toDegrees(math.Asin(clamp(z, -1, 1))))
+		}
+	}
+	return
+}
+
+func (p *Point) BoundingBoxes(distance float64) []BoundingBox {
+	return NewBoundingBox(*p, *p).Pad(distance)
+}
+
+// Pad returns boxes covering every point within distance meters of the box,
+// split at the antimeridian.
+func (b BoundingBox) Pad(distance float64) []BoundingBox {
+	dlat := toDegrees(distance / EarthRadius)
+	minLat, maxLat := b.MinLatitude-dlat, b.MaxLatitude+dlat
+	if minLat <= -90 || maxLat >= 90 {
+		return []BoundingBox{{MinLongitude: -180, MinLatitude: math.Max(minLat, -90), MaxLongitude: 180, MaxLatitude: math.Min(maxLat, 90)}}
+	}
+
+	ratio := math.Sin(distance/EarthRadius) / math.Cos(toRadians(math.Max(math.Abs(b.MinLatitude), math.Abs(b.MaxLatitude))))
+	if ratio >= 1 || distance/EarthRadius >= math.Pi/2 {
+		return []BoundingBox{{MinLongitude: -180, MinLatitude: minLat, MaxLongitude: 180, MaxLatitude: maxLat}}
+	}
+
+	dlong := toDegrees(math.Asin(ratio))
+	minLong, maxLong := b.MinLongitude-dlong, b.MaxLongitude+dlong
+	if maxLong-minLong >= 360 {
+		return []BoundingBox{{MinLongitude: -180, MinLatitude: minLat, MaxLongitude: 180, MaxLatitude: maxLat}}
+	} else if minLong < -180 {
+		return []BoundingBox{
+			{MinLongitude: minLong + 360, MinLatitude: minLat, MaxLongitude: 180, MaxLatitude: maxLat},
+			{MinLongitude: -180, MinLatitude: minLat, MaxLongitude: maxLong, MaxLatitude: maxLat},
+		}
+	} else if maxLong > 180 {
+		return []BoundingBox{
+			{MinLongitude: minLong, MinLatitude: minLat, MaxLongitude: 180, MaxLatitude: maxLat},
+			{MinLongitude: -180, MinLatitude: minLat, MaxLongitude: maxLong - 360, MaxLatitude: maxLat},
+		}
+	}
+	return []BoundingBox{{MinLongitude: minLong, MinLatitude: minLat, MaxLongitude: maxLong, MaxLatitude: maxLat}}
+}
+
+func (b BoundingBox) area() float64 {
+	return (b.MaxLongitude - b.MinLongitude) * (b.MaxLatitude - b.MinLatitude)
+}
+
+func (b BoundingBox) center() (float64, float64) {
+	return (b.MinLongitude + b.MaxLongitude) / 2, (b.MinLatitude + b.MaxLatitude) / 2
+}
@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// containedIn allows a centimetre for rounding in MoveTowards, which is
+// ill-conditioned on arcs passing close to a pole.
+func containedIn(boxes []BoundingBox, point Point) bool {
+	for _, box := range boxes {
+		if box.Distance(point) < 0.01 {
+			return true
+		}
+	}
+	return false
+}
+
+// seamAndPolePoints samples points near the antimeridian and both poles.
+func seamAndPolePoints(random *rand.Rand, count int) []Point {
+	points := make([]Point, 0, count)
+	for i := 0; i < count; i++ {
+		switch i % 3 {
+		case 0:
+			points = append(points, Point{Longitude: NormalizeLongitude(179.99 + random.Float64()*0.02), Latitude: random.Float64()*160 - 80})
+		case 1:
+			points = append(points, Point{Longitude: random.Float64()*360 - 180, Latitude: 89.9 + random.Float64()*0.1})
+		default:
+			points = append(points, Point{Longitude: random.Float64()*360 - 180, Latitude: -89.9 - random.Float64()*0.1})
+		}
+	}
+	return points
+}
+
+func TestPointBoundingBoxesCoverTheRadius(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, center := range seamAndPolePoints(random, 300) {
+		for _, radius := range []float64{10, 1000, 50000} {
+			boxes := center.BoundingBoxes(radius)
+			for i := 0; i < 20; i++ {
+				target := Point{Longitude: random.Float64()*360 - 180, Latitude: random.Float64()*180 - 90}
+				point := center.MoveTowards(target, random.Float64()*radius)
+				if !containedIn(boxes, point) {
+					t.Fatalf("%v is %vm from %v but outside %+v", point, center.Distance(point), center, boxes)
+				}
+			}
+		}
+	}
+}
+
+func TestArcBoundingBoxesCoverTheArc(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	points := seamAndPolePoints(random, 300)
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if i%2 == 0 {
+			b = Point{Longitude: NormalizeLongitude(a.Longitude + 180 - random.Float64()), Latitude: a.Latitude}
+		}
+		boxes := NewArcBoundingBoxes(a, b)
+		for _, fraction := range []float64{0, 0.1, 0.25, 0.5, 0.75, 0.9, 1} {
+			point := a.MoveTowards(b, fraction*a.Distance(b))
+			if !containedIn(boxes, point) {
+				t.Fatalf("%v at %v of the arc from %v to %v is outside %+v", point, fraction, a, b, boxes)
+			}
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package pkg
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+type Router interface {
+	Distance(start, end string, maxDistance float64, reverse bool) (float64, error)
+	Path(start, end string, maxDistance float64, reverse bool) ([]*Edge, error)
+}
+
+type csrItem struct {
+	node     int32
+	distance float64
+}
+
+func csrLess(a, b interface{}) bool {
+	if a.(csrItem).distance == b.(csrItem).distance {
+		return a.(csrItem).node < b.(csrItem).node
+	}
+	return a.(csrItem).distance < b.(csrItem).distance
+}
+
+// csrSearch is the resumable state of the latest search in one direction. Its
+// slices are indexed by node and reused by the next search: a node only holds
+// a value when its stamp matches the current generation.
+type csrSearch struct {
+	Source      int32
+	MaxDistance float64
+	Generation  uint32
+	Reached     []uint32
+	Settled     []uint32
+	Distances   []float64
+	Lengths     []float64
+	Parents     []int32
+	Queue       *Heap
+}
+
+func newCSRSearch(nodes int) *csrSearch {
+	return &csrSearch{
+		Reached:   make([]uint32, nodes),
+		Settled:   make([]uint32, nodes),
+		Distances: make([]float64, nodes),
+		Lengths:   make([]float64, nodes),
+		Parents:   make([]int32, nodes),
+		Queue:     NewHeap(csrLess),
+	}
+}
+
+func (s *csrSearch) reset(source int32, maxDistance float64) {
+	s.Generation++
+	if s.Generation == 0 {
+		clear(s.Reached)
+		clear(s.Settled)
+		s.Generation = 1
+	}
+	s.Source, s.MaxDistance = source, maxDistance
+	clear(s.Queue.Objects[1:])
+	s.Queue.Objects = s.Queue.Objects[:1]
+
+	s.Reached[source], s.Distances[source], s.Lengths[source] = s.Generation, 0, 0
+	s.Queue.Push(csrItem{node: source})
+}
+
+func (s *csrSearch) reached(node int32) bool {
+	return s.Reached[node] == s.Generation
+}
+
+type CSRGraph struct {
+	NodeIDs    []string
+	Positions  []Point
+	Edges      []*Edge
+	EdgeStart  []int32
+	EdgeEnd    []int32
+	EdgeLength []float64
+	EdgeCost   []float64
+	OutOffsets []int32
+	OutEdges   []int32
+	InOffsets  []int32
+	InEdges    []int32
+	nodeIndex  map[string]int32
+	edgeIndex  map[string]int32
+	graph      *Graph
+	revision   uint64
+	topology   uint64
+	scale      float64
+	searches   [2]*csrSearch
+}
+
+func NewCSRGraph(graph *Graph) *CSRGraph {
+	c := &CSRGraph{graph: graph}
+	c.build()
+	return c
+}
+
+func (c *CSRGraph) build() {
+	g := c.graph
+	c.revision, c.topology, c.scale = g.revision, g.topology, g.costScale()
+	c.searches = [2]*csrSearch{}
+
+	c.NodeIDs = make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		c.NodeIDs = append(c.NodeIDs, id)
+	}
+	slices.Sort(c.NodeIDs)
+
+	c.nodeIndex = make(map[string]int32, len(c.NodeIDs))
+	c.Positions = make([]Point, len(c.NodeIDs))
+	for i, id := range c.NodeIDs {
+		c.nodeIndex[id] = int32(i)
+		c.Positions[i] = g.Nodes[id].Position
+	}
+
+	c.Edges = make([]*Edge, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		c.Edges = append(c.Edges, edge)
+	}
+	slices.SortFunc(c.Edges, func(a, b *Edge) int { return cmp.Compare(a.ID, b.ID) })
+
+	c.edgeIndex = make(map[string]int32, len(c.Edges))
+	c.EdgeStart, c.EdgeEnd = make([]int32, len(c.Edges)), make([]int32, len(c.Edges))
+	c.EdgeLength, c.EdgeCost = make([]float64, len(c.Edges)), make([]float64, len(c.Edges))
+	for i, edge := range c.Edges {
+		c.edgeIndex[edge.ID] = int32(i)
+		c.EdgeStart[i], c.EdgeEnd[i] = c.nodeIndex[edge.Start], c.nodeIndex[edge.End]
+		c.EdgeLength[i], c.EdgeCost[i] = edge.Length, g.EdgeCost(edge)
+	}
+
+	c.OutOffsets, c.OutEdges = adjacency(len(c.NodeIDs), c.EdgeStart)
+	c.InOffsets, c.InEdges = adjacency(len(c.NodeIDs), c.EdgeEnd)
+}
+
+func adjacency(nodes int, endpoints []int32) (offsets, edges []int32) {
+	offsets = make([]int32, nodes+1)
+	for _, node := range endpoints {
+		offsets[node+1]++
+	}
+	for i := 1; i <= nodes; i++ {
+		offsets[i] += offsets[i-1]
+	}
+
+	edges = make([]int32, len(endpoints))
+	next := slices.Clone(offsets[:nodes])
+	for edge, node := range endpoints {
+		edges[next[node]] = int32(edge)
+		next[node]++
+	}
+	return
+}
+
+// refresh rebuilds the CSR after edges were added or removed; override
+// changes only patch the costs of the edges they touched.
+func (c *CSRGraph) refresh() {
+	g := c.graph
+	if c.revision == g.revision {
+		return
+	}
+	if c.topology != g.topology {
+		c.build()
+		return
+	}
+
+	for edge, revision := range g.costChanged {
+		if revision > c.revision {
+			c.EdgeCost[c.edgeIndex[edge.ID]] = g.EdgeCost(edge)
+		}
+	}
+	c.revision = g.revision
+	c.resetSearches()
+}
+
+// resetSearches makes the next query start a new search; -1 never matches a
+// node.
+func (c *CSRGraph) resetSearches() {
+	for _, s := range c.searches {
+		if s != nil {
+			s.Source = -1
+		}
+	}
+}
+
+func (c *CSRGraph) NodeIndex(id string) (int32, bool) {
+	c.refresh()
+	index, ok := c.nodeIndex[id]
+	return index, ok
+}
+
+func (c *CSRGraph) EdgeIndex(id string) (int32, bool) {
+	c.refresh()
+	index, ok := c.edgeIndex[id]
+	return index, ok
+}
+
+func (c *CSRGraph) Neighbours(node int32, reverse bool) []int32 {
+	if reverse {
+		return c.InEdges[c.InOffsets[node]:c.InOffsets[node+1]]
+	}
+	return c.OutEdges[c.OutOffsets[node]:c.OutOffsets[node+1]]
+}
+
+func (c *CSRGraph) search(start int32, maxDistance float64, reverse bool) *csrSearch {
+	maxDistance *= c.scale
+	direction := 0
+	if reverse {
+		direction = 1
+	}
+	s := c.searches[direction]
+	if s == nil {
+		s = newCSRSearch(len(c.NodeIDs))
+		c.searches[direction] = s
+	}
+	if s.Generation == 0 || s.Source != start {
+		s.reset(start, maxDistance)
+	} else if s.MaxDistance < maxDistance {
+		s.MaxDistance = maxDistance
+	} else {
+		return s
+	}
+
+	for s.Queue.Length() > 0 {
+		if maxDistance > 0 && s.Queue.Peek().(csrItem).distance > maxDistance {
+			break
+		}
+		current := s.Queue.Pop().(csrItem)
+		if s.Settled[current.node] == s.Generation {
+			continue
+		}
+		s.Settled[current.node] = s.Generation
+
+		for _, edge := range c.Neighbours(current.node, reverse) {
+			neighbour := c.EdgeEnd[edge]
+			if reverse {
+				neighbour = c.EdgeStart[edge]
+			}
+			if s.Settled[neighbour] == s.Generation {
+				continue
+			}
+
+			distance := current.distance + c.EdgeCost[edge]
+			if math.IsInf(distance, 1) {
+				continue
+			}
+			if !s.reached(neighbour) || distance < s.Distances[neighbour] {
+				s.Reached[neighbour], s.Distances[neighbour], s.Parents[neighbour] = s.Generation, distance, edge
+				s.Lengths[neighbour] = s.Lengths[current.node] + c.EdgeLength[edge]
+				s.Queue.Push(csrItem{node: neighbour, distance: distance})
+			}
+		}
+	}
+	return s
+}
+
+func (c *CSRGraph) ShortestPath(start, end int32, maxDistance float64, reverse bool) (float64, []int32, error) {
+	s := c.search(start, maxDistance, reverse)
+	if !s.reached(end) {
+		return -1, nil, ErrNodeNotReachable
+	}
+
+	path := make([]int32, 0)
+	for current := end; current != start; {
+		edge := s.Parents[current]
+		path = append(path, edge)
+		if reverse {
+			current = c.EdgeEnd[edge]
+		} else {
+			current = c.EdgeStart[edge]
+		}
+	}
+	return s.Lengths[end], path, nil
+}
+
+func (c *CSRGraph) nodes(start, end string) (int32, int32, error) {
+	c.refresh()
+	from, ok := c.nodeIndex[start]
+	if !ok {
+		return 0, 0, ErrNodeNotFound
+	}
+	to, ok := c.nodeIndex[end]
+	if !ok {
+		return 0, 0, ErrNodeNotFound
+	}
+	return from, to, nil
+}
+
+func (c *CSRGraph) Distance(start, end string, maxDistance float64, reverse bool) (float64, error) {
+	from, to, err := c.nodes(start, end)
+	if err != nil {
+		return -1, err
+	}
+	s := c.search(from, maxDistance, reverse)
+	if s.reached(to) {
+		return s.Lengths[to], nil
+	}
+	return -1, ErrNodeNotReachable
+}
+
+func (c *CSRGraph) Path(start, end string, maxDistance float64, reverse bool) ([]*Edge, error) {
+	from, to, err := c.nodes(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	_, indices, err := c.ShortestPath(from, to, maxDistance, reverse)
+	if err != nil {
+		return nil, err
+	}
+	path := make([]*Edge, len(indices))
+	for i, index := range indices {
+		path[i] = c.Edges[index]
+	}
+	return path, nil
+}
@@ -0,0 +1,151 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+const (
+	benchmarkGridSize    = 60
+	benchmarkGridSpacing = 0.001
+	benchmarkHorizon     = 2000.0
+)
+
+func routerPairs(size, count int) [][2]string {
+	random := rand.New(rand.NewSource(1))
+	pairs := make([][2]string, count)
+	for i := range pairs {
+		pairs[i] = [2]string{
+			fmt.Sprintf("%d,%d", random.Intn(size), random.Intn(size)),
+			fmt.Sprintf("%d,%d", random.Intn(size), random.Intn(size)),
+		}
+	}
+	return pairs
+}
+
+func TestCSRMatchesGraph(t *testing.T) {
+	g := NewGridGraph(15, benchmarkGridSpacing)
+	g.SetCost(func(edge *Edge) float64 { return edge.Length / edge.Speed })
+	c := NewCSRGraph(g)
+	for _, pair := range routerPairs(15, 300) {
+		for _, reverse := range []bool{false, true} {
+			expected, expectedErr := g.Distance(pair[0], pair[1], 0, reverse)
+			got, err := c.Distance(pair[0], pair[1], 0, reverse)
+			if (err == nil) != (expectedErr == nil) || math.Abs(got-expected) > 1e-6 {
+				t.Fatalf("%s→%s reverse=%v: csr %v (%v), graph %v (%v)", pair[0], pair[1], reverse, got, err, expected, expectedErr)
+			}
+		}
+	}
+}
+
+func TestCSRSearchResumesAndResets(t *testing.T) {
+	g := NewGridGraph(10, benchmarkGridSpacing)
+	c := NewCSRGraph(g)
+	for _, query := range []struct {
+		start, end string
+		horizon    float64
+	}{
+		{"0,0", "9,9", 300},
+		{"0,0", "9,9", 0},
+		{"0,0", "2,2", 100},
+		{"5,5", "0,0", 0},
+		{"0,0", "9,9", 300},
+		{"0,0", "1,1", 300},
+	} {
+		expected, expectedErr := g.Distance(query.start, query.end, query.horizon, false)
+		got, err := c.Distance(query.start, query.end, query.horizon, false)
+		if (err == nil) != (expectedErr == nil) || math.Abs(got-expected) > 1e-6 {
+			t.Errorf("%s→%s within %v: csr %v (%v), graph %v (%v)", query.start, query.end, query.horizon, got, err, expected, expectedErr)
+		}
+	}
+	if c.searches[0].Generation != 3 {
+		t.Errorf("%d searches started, want 3", c.searches[0].Generation)
+	}
+}
+
+func TestCSRRebuildsAfterEdgeChanges(t *testing.T) {
+	g := NewGridGraph(3, benchmarkGridSpacing)
+	c := NewCSRGraph(g)
+	if _, err := c.Distance("0,0", "2,2", 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	removed := []*Edge{g.Edges["0,0>0,1"], g.Edges["0,0>1,0"]}
+	for _, edge := range removed {
+		if err := g.RemoveEdge(edge.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Distance("0,0", "2,2", 0, false); !errors.Is(err, ErrNodeNotReachable) {
+		t.Errorf("distance without the edges leaving 0,0: %v, want %v", err, ErrNodeNotReachable)
+	}
+
+	if err := g.InsertEdge(removed[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Distance("0,0", "2,2", 0, false); err != nil {
+		t.Errorf("distance after reinserting %s: %v", removed[0].ID, err)
+	}
+}
+
+func retainedHeap(build func()) float64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	build()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	return float64(after.HeapAlloc) - float64(before.HeapAlloc)
+}
+
+func BenchmarkRouterMemory(b *testing.B) {
+	b.Run("graph", func(b *testing.B) {
+		var g *Graph
+		retained := retainedHeap(func() { g = NewGridGraph(benchmarkGridSize, benchmarkGridSpacing) })
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			NewGridGraph(benchmarkGridSize, benchmarkGridSpacing)
+		}
+		b.ReportMetric(retained, "retained-B")
+		runtime.KeepAlive(g)
+	})
+	b.Run("csr", func(b *testing.B) {
+		g := NewGridGraph(benchmarkGridSize, benchmarkGridSpacing)
+		var c *CSRGraph
+		retained := retainedHeap(func() { c = NewCSRGraph(g) })
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			NewCSRGraph(g)
+		}
+		b.ReportMetric(retained, "retained-B")
+		runtime.KeepAlive(c)
+	})
+}
+
+func BenchmarkRouterDistance(b *testing.B) {
+	g, pairs := NewGridGraph(benchmarkGridSize, benchmarkGridSpacing), routerPairs(benchmarkGridSize, 1024)
+	c := NewCSRGraph(g)
+	for _, router := range []struct {
+		name   string
+		router Router
+		reset  func()
+	}{
+		{"graph", g, g.resetCache},
+		{"csr", c, c.resetSearches},
+	} {
+		b.Run(router.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				router.reset()
+				pair := pairs[i%len(pairs)]
+				router.router.Distance(pair[0], pair[1], benchmarkHorizon, false)
+			}
+		})
+	}
+}
@@ -0,0 +1,522 @@
+package pkg
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	ExtractKeep = "keep"
+	ExtractClip = "clip"
+)
+
+const (
+	ExtractSampleDistance = 10.0
+	ClipIterations        = 40
+)
+
+var (
+	ErrUnknownExtractMode = errors.New("unknown extract mode")
+	ErrInvalidRegion      = errors.New("invalid region")
+)
+
+// Region is tested in the plane of longitude and latitude; segments take the
+// short way round the antimeridian.
+type Region interface {
+	Contains(point Point) bool
+	IntersectsSegment(a, b Point) bool
+	Bounds() []BoundingBox
+}
+
+func (b BoundingBox) Bounds() []BoundingBox {
+	return []BoundingBox{b}
+}
+
+func unwrapTowards(a, b Point) Point {
+	b.Longitude = a.Longitude + longitudeDifference(a.Longitude, b.Longitude)
+	return b
+}
+
+func shifted(point Point, shift float64) Point {
+	point.Longitude += shift
+	return point
+}
+
+func (b BoundingBox) IntersectsSegment(p, q Point) bool {
+	q = unwrapTowards(p, q)
+	for _, shift := range []float64{0, 360, -360} {
+		if b.clips(shifted(p, shift), shifted(q, shift)) {
+			return true
+		}
+	}
+	return false
+}
+
+// clips is the Liang-Barsky test of segment pq against the box.
+func (b BoundingBox) clips(p, q Point) bool {
+	dx, dy := q.Longitude-p.Longitude, q.Latitude-p.Latitude
+	low, high := 0.0, 1.0
+	for _, side := range [][2]float64{
+		{-dx, p.Longitude - b.MinLongitude},
+		{dx, b.MaxLongitude - p.Longitude},
+		{-dy, p.Latitude - b.MinLatitude},
+		{dy, b.MaxLatitude - p.Latitude},
+	} {
+		if side[0] == 0 {
+			if side[1] < 0 {
+				return false
+			}
+			continue
+		}
+		if t := side[1] / side[0]; side[0] < 0 {
+			low = math.Max(low, t)
+		} else {
+			high = math.Min(high, t)
+		}
+		if low > high {
+			return false
+		}
+	}
+	return true
+}
+
+func orientation(p, q, r Point) float64 {
+	return (q.Longitude-p.Longitude)*(r.Latitude-p.Latitude) - (q.Latitude-p.Latitude)*(r.Longitude-p.Longitude)
+}
+
+// segmentsCross reports whether segments ab and cd touch in the plane.
+func segmentsCross(a, b, c, d Point) bool {
+	return orientation(c, d, a)*orientation(c, d, b) <= 0 && orientation(a, b, c)*orientation(a, b, d) <= 0 &&
+		NewBoundingBox(a, b).Intersects(NewBoundingBox(c, d))
+}
+
+// Polygon is a single ring without holes, tested in the plane of longitude and
+// latitude. The ring may cross the antimeridian but must not enclose a pole.
+type Polygon []Point
+
+func (p Polygon) unwrap() Polygon {
+	ring := slices.Clone(p)
+	for i := 1; i < len(ring); i++ {
+		ring[i].Longitude = ring[i-1].Longitude + longitudeDifference(ring[i-1].Longitude, ring[i].Longitude)
+	}
+	return ring
+}
+
+func (p Polygon) Validate() error {
+	if len(p) < 3 {
+		return fmt.Errorf("%w: a polygon needs at least three vertices", ErrInvalidRegion)
+	}
+	ring := p.unwrap()
+	last := ring[len(ring)-1]
+	if winding := last.Longitude + longitudeDifference(last.Longitude, ring[0].Longitude) - ring[0].Longitude; math.Abs(winding) > 180 {
+		return fmt.Errorf("%w: polygons enclosing a pole are not supported", ErrInvalidRegion)
+	}
+	return nil
+}
+
+func (p Polygon) Contains(point Point) bool {
+	var inside [3]bool
+	ring := p.unwrap()
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > point.Latitude) == (b.Latitude > point.Latitude) {
+			continue
+		}
+		crossing := a.Longitude + (point.Latitude-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude)
+		for k, shift := range []float64{0, 360, -360} {
+			if point.Longitude+shift < crossing {
+				inside[k] = !inside[k]
+			}
+		}
+	}
+	return inside[0] || inside[1] || inside[2]
+}
+
+func (p Polygon) IntersectsSegment(a, b Point) bool {
+	if p.Contains(a) || p.Contains(b) {
+		return true
+	}
+	ring, b := p.unwrap(), unwrapTowards(a, b)
+	for _, shift := range []float64{0, 360, -360} {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			if segmentsCross(shifted(a, shift), shifted(b, shift), ring[j], ring[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p Polygon) Bounds() []BoundingBox {
+	if len(p) == 0 {
+		return nil
+	}
+	ring := p.unwrap()
+	box := NewBoundingBox(ring[0], ring[0])
+	for _, point := range ring[1:] {
+		box = box.Union(NewBoundingBox(point, point))
+	}
+
+	switch {
+	case box.MaxLongitude-box.MinLongitude >= 360:
+		box.MinLongitude, box.MaxLongitude = -180, 180
+	case box.MinLongitude < -180:
+		east := box
+		east.MinLongitude, east.MaxLongitude = box.MinLongitude+360, 180
+		box.MinLongitude = -180
+		return []BoundingBox{east, box}
+	case box.MaxLongitude > 180:
+		west := box
+		west.MinLongitude, west.MaxLongitude = -180, box.MaxLongitude-360
+		box.MaxLongitude = 180
+		return []BoundingBox{box, west}
+	}
+	return []BoundingBox{box}
+}
+
+type Corridor struct {
+	Trace  []Point
+	Buffer float64
+}
+
+func (c *Corridor) Contains(point Point) bool {
+	if len(c.Trace) == 1 {
+		return point.Distance(c.Trace[0]) <= c.Buffer
+	}
+	for i := 1; i < len(c.Trace); i++ {
+		if point.Distance(point.ClosestPointOnSegment(c.Trace[i-1], c.Trace[i])) <= c.Buffer {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Corridor) IntersectsSegment(a, b Point) bool {
+	if len(c.Trace) == 1 {
+		return c.Trace[0].Distance(c.Trace[0].ClosestPointOnSegment(a, b)) <= c.Buffer
+	}
+	for i := 1; i < len(c.Trace); i++ {
+		start, end := c.Trace[i-1], c.Trace[i]
+		if segmentsCross(a, unwrapTowards(a, b), unwrapTowards(a, start), unwrapTowards(a, end)) ||
+			a.Distance(a.ClosestPointOnSegment(start, end)) <= c.Buffer || b.Distance(b.ClosestPointOnSegment(start, end)) <= c.Buffer ||
+			start.Distance(start.ClosestPointOnSegment(a, b)) <= c.Buffer || end.Distance(end.ClosestPointOnSegment(a, b)) <= c.Buffer {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Corridor) Bounds() (boxes []BoundingBox) {
+	for i, point := range c.Trace {
+		radius := c.Buffer
+		if i+1 < len(c.Trace) {
+			radius += point.Distance(c.Trace[i+1])
+		}
+		boxes = append(boxes, point.BoundingBoxes(radius)...)
+	}
+	return
+}
+
+type Union []Region
+
+func (u Union) Contains(point Point) bool {
+	return slices.ContainsFunc(u, func(region Region) bool { return region.Contains(point) })
+}
+
+func (u Union) IntersectsSegment(a, b Point) bool {
+	return slices.ContainsFunc(u, func(region Region) bool { return region.IntersectsSegment(a, b) })
+}
+
+func (u Union) Bounds() (boxes []BoundingBox) {
+	for _, region := range u {
+		boxes = append(boxes, region.Bounds()...)
+	}
+	return
+}
+
+func intersectsRegion(edge *Edge, bounds []BoundingBox) bool {
+	for i := 1; i < len(edge.Poly); i++ {
+		for _, box := range NewArcBoundingBoxes(edge.Poly[i-1], edge.Poly[i]) {
+			if slices.ContainsFunc(bounds, box.Intersects) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type clipPoint struct {
+	Point    Point
+	Inside   bool
+	Boundary bool
+}
+
+func boundary(region Region, inside, outside Point) Point {
+	for i := 0; i < ClipIterations; i++ {
+		middle := inside.MoveTowards(outside, inside.Distance(outside)/2)
+		if region.Contains(middle) {
+			inside = middle
+		} else {
+			outside = middle
+		}
+	}
+	return inside
+}
+
+func samplePolyline(region Region, poly []Point) []clipPoint {
+	previous := clipPoint{Point: poly[0], Inside: region.Contains(poly[0])}
+	samples := []clipPoint{previous}
+	for i := 1; i < len(poly); i++ {
+		a, b := poly[i-1], poly[i]
+		steps := max(1, int(math.Ceil(a.Distance(b)/ExtractSampleDistance)))
+		for k := 1; k <= steps; k++ {
+			current := clipPoint{Point: b, Inside: region.Contains(b)}
+			if k < steps {
+				current.Point = a.MoveTowards(b, a.Distance(b)*float64(k)/float64(steps))
+				current.Inside = region.Contains(current.Point)
+			}
+
+			if previous.Inside && !current.Inside {
+				samples = append(samples, clipPoint{Point: boundary(region, previous.Point, current.Point), Inside: true, Boundary: true})
+			} else if !previous.Inside && current.Inside {
+				samples = append(samples, clipPoint{Point: boundary(region, current.Point, previous.Point), Inside: true, Boundary: true})
+			}
+			if k == steps {
+				samples = append(samples, current)
+			}
+			previous = current
+		}
+	}
+	return samples
+}
+
+type clipPiece struct {
+	Poly       []Point
+	StartIndex int
+	EndIndex   int
+}
+
+func clipPolyline(region Region, poly []Point) (pieces []clipPiece) {
+	var current *clipPiece
+	crossings := 0
+	for _, sample := range samplePolyline(region, poly) {
+		if sample.Boundary {
+			crossings++
+		}
+		if !sample.Inside {
+			current = nil
+			continue
+		}
+
+		if current == nil {
+			pieces = append(pieces, clipPiece{StartIndex: -1, EndIndex: -1})
+			current = &pieces[len(pieces)-1]
+			if sample.Boundary {
+				current.StartIndex = crossings - 1
+			}
+		}
+		if len(current.Poly) == 0 || current.Poly[len(current.Poly)-1] != sample.Point {
+			current.Poly = append(current.Poly, sample.Point)
+		}
+		if sample.Boundary && len(current.Poly) > 1 {
+			current.EndIndex = crossings - 1
+		}
+	}
+	return slices.DeleteFunc(pieces, func(piece clipPiece) bool { return len(piece.Poly) < 2 })
+}
+
+type extractor struct {
+	source *Graph
+	result *Graph
+	region Region
+	pieces map[*Edge][]*Edge
+}
+
+func (x *extractor) node(id string, position Point) (*Node, error) {
+	if node, ok := x.result.Nodes[id]; ok {
+		return node, nil
+	}
+	return x.result.AddNode(id, position)
+}
+
+func (x *extractor) copyEdge(edge *Edge, id string, start, end *Node, poly []Point) (*Edge, error) {
+	copied, err := x.result.AddEdge(id, start, end, edge.Speed, poly)
+	if err != nil {
+		return nil, fmt.Errorf("edge %s: %w", id, err)
+	}
+	copied.OSMWayID, copied.OSMNodeIDs, copied.Isolated, copied.Attributes = edge.OSMWayID, edge.OSMNodeIDs, edge.Isolated, edge.Attributes
+	copied.Profile = edge.Profile
+	return copied, nil
+}
+
+func intersectsPolyline(region Region, poly []Point) bool {
+	for i := 1; i < len(poly); i++ {
+		if region.IntersectsSegment(poly[i-1], poly[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *extractor) keep(edge *Edge) error {
+	if !intersectsPolyline(x.region, edge.Poly) {
+		return nil
+	}
+
+	start, end := x.source.Nodes[edge.Start], x.source.Nodes[edge.End]
+	resultStart, err := x.node(start.ID, start.Position)
+	if err != nil {
+		return err
+	}
+	resultEnd, err := x.node(end.ID, end.Position)
+	if err != nil {
+		return err
+	}
+	_, err = x.copyEdge(edge, edge.ID, resultStart, resultEnd, slices.Clone(edge.Poly))
+	return err
+}
+
+func clipNodeID(edge *Edge, index int) string {
+	return fmt.Sprintf("%s_clip_%d", edge.ID, index)
+}
+
+func pieceID(edge *Edge, index, count int) string {
+	if count == 1 {
+		return edge.ID
+	}
+	if base, ok := strings.CutSuffix(edge.ID, ReverseSuffix); ok {
+		return fmt.Sprintf("%s_%d%s", base, count-1-index, ReverseSuffix)
+	}
+	return fmt.Sprintf("%s_%d", edge.ID, index)
+}
+
+func (x *extractor) clipTwin(edge, twin *Edge) error {
+	pieces := x.pieces[twin]
+	for i := len(pieces) - 1; i >= 0; i-- {
+		piece := pieces[i]
+		id := pieceID(edge, len(pieces)-1-i, len(pieces))
+		if _, err := x.copyEdge(edge, id, x.result.Nodes[piece.End], x.result.Nodes[piece.Start], reversePolyline(piece.Poly)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extractor) clip(edge *Edge) error {
+	if base, ok := strings.CutSuffix(edge.ID, ReverseSuffix); ok {
+		if twin, ok := x.source.Edges[base]; ok && edge.IsReverseOf(twin) && slices.Equal(edge.Poly, reversePolyline(twin.Poly)) {
+			return x.clipTwin(edge, twin)
+		}
+	}
+
+	pieces := clipPolyline(x.region, edge.Poly)
+	for i, piece := range pieces {
+		startID, startPosition := clipNodeID(edge, piece.StartIndex), piece.Poly[0]
+		if piece.StartIndex < 0 {
+			startID, startPosition = edge.Start, x.source.Nodes[edge.Start].Position
+		}
+		endID, endPosition := clipNodeID(edge, piece.EndIndex), piece.Poly[len(piece.Poly)-1]
+		if piece.EndIndex < 0 {
+			endID, endPosition = edge.End, x.source.Nodes[edge.End].Position
+		}
+
+		start, err := x.node(startID, startPosition)
+		if err != nil {
+			return err
+		}
+		end, err := x.node(endID, endPosition)
+		if err != nil {
+			return err
+		}
+		copied, err := x.copyEdge(edge, pieceID(edge, i, len(pieces)), start, end, piece.Poly)
+		if err != nil {
+			return err
+		}
+		x.pieces[edge] = append(x.pieces[edge], copied)
+	}
+	return nil
+}
+
+func reversePolyline(points []Point) []Point {
+	reversed := slices.Clone(points)
+	slices.Reverse(reversed)
+	return reversed
+}
+
+func Extract(graph *Graph, region Region, mode string) (*Graph, error) {
+	x := &extractor{source: graph, result: NewGraph(), region: region, pieces: make(map[*Edge][]*Edge)}
+	visit := x.keep
+	switch mode {
+	case ExtractKeep:
+	case ExtractClip:
+		visit = x.clip
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownExtractMode, mode)
+	}
+
+	bounds := region.Bounds()
+	if len(bounds) == 0 {
+		return nil, ErrInvalidRegion
+	}
+
+	edges := candidateEdges(graph, bounds)
+	slices.SortStableFunc(edges, func(a, b *Edge) int {
+		return boolOrder(strings.HasSuffix(a.ID, ReverseSuffix)) - boolOrder(strings.HasSuffix(b.ID, ReverseSuffix))
+	})
+	for _, edge := range edges {
+		if len(edge.Poly) < 2 || !intersectsRegion(edge, bounds) {
+			continue
+		}
+		if err := visit(edge); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, node := range graph.sortedNodes() {
+		if _, ok := x.result.Nodes[node.ID]; !ok && region.Contains(node.Position) {
+			if _, err := x.result.AddNode(node.ID, node.Position); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return x.result, nil
+}
+
+// candidateEdges looks the bounds up in the spatial index when there is one;
+// isolated edges are not indexed and are always checked. Edges come sorted by
+// ID.
+func candidateEdges(graph *Graph, bounds []BoundingBox) []*Edge {
+	if graph.Index == nil {
+		return graph.sortedEdges()
+	}
+
+	seen := make(map[*Edge]bool)
+	for _, box := range bounds {
+		for _, edge := range graph.Index.Intersecting(box) {
+			seen[edge] = true
+		}
+	}
+	for _, edge := range graph.Edges {
+		if edge.Isolated {
+			seen[edge] = true
+		}
+	}
+
+	edges := make([]*Edge, 0, len(seen))
+	for edge := range seen {
+		edges = append(edges, edge)
+	}
+	slices.SortFunc(edges, func(a, b *Edge) int { return cmp.Compare(a.ID, b.ID) })
+	return edges
+}
+
+func boolOrder(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
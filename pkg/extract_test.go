@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolygonContains(t *testing.T) {
+	square := Polygon{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}
+	seam := Polygon{{179, 0}, {-179, 0}, {-179, 1}, {179, 1}, {179, 0}}
+	for _, test := range []struct {
+		name    string
+		polygon Polygon
+		point   Point
+		want    bool
+	}{
+		{"inside", square, Point{0.5, 0.5}, true},
+		{"outside", square, Point{1.5, 0.5}, false},
+		{"inside west of the antimeridian", seam, Point{179.5, 0.5}, true},
+		{"inside east of the antimeridian", seam, Point{-179.5, 0.5}, true},
+		{"on the antimeridian", seam, Point{180, 0.5}, true},
+		{"opposite side of the globe", seam, Point{0, 0.5}, false},
+		{"outside beyond the seam", seam, Point{-178.5, 0.5}, false},
+	} {
+		if got := test.polygon.Contains(test.point); got != test.want {
+			t.Errorf("%s: contains %v = %v, want %v", test.name, test.point, got, test.want)
+		}
+	}
+}
+
+func TestPolygonBoundsSplitAtTheAntimeridian(t *testing.T) {
+	seam := Polygon{{179, 0}, {-179, 0}, {-179, 1}, {179, 1}, {179, 0}}
+	bounds := seam.Bounds()
+	if len(bounds) != 2 {
+		t.Fatalf("bounds %v, want two boxes", bounds)
+	}
+	for _, point := range []Point{{179.5, 0.5}, {-179.5, 0.5}} {
+		if !bounds[0].Contains(point) && !bounds[1].Contains(point) {
+			t.Errorf("bounds %v miss %v", bounds, point)
+		}
+	}
+	if bounds[0].Contains(Point{0, 0.5}) || bounds[1].Contains(Point{0, 0.5}) {
+		t.Errorf("bounds %v span the whole globe", bounds)
+	}
+}
+
+func TestPolygonValidate(t *testing.T) {
+	if err := (Polygon{{179, 0}, {-179, 0}, {-179, 1}, {179, 0}}).Validate(); err != nil {
+		t.Errorf("ring across the antimeridian: %v", err)
+	}
+	polar := Polygon{{0, 80}, {90, 80}, {180, 80}, {-90, 80}, {0, 80}}
+	if err := polar.Validate(); !errors.Is(err, ErrInvalidRegion) {
+		t.Errorf("ring around the pole: %v, want %v", err, ErrInvalidRegion)
+	}
+	if err := (Polygon{{0, 0}, {1, 1}}).Validate(); !errors.Is(err, ErrInvalidRegion) {
+		t.Errorf("two vertices: %v, want %v", err, ErrInvalidRegion)
+	}
+}
+
+func TestRegionsIntersectSegments(t *testing.T) {
+	box := NewBoundingBox(Point{0, 0}, Point{1, 1})
+	seamBox := NewBoundingBox(Point{179, 0}, Point{180, 1})
+	square := Polygon{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	corridor := &Corridor{Trace: []Point{{0, 0}, {0, 0.01}}, Buffer: 100}
+	for _, test := range []struct {
+		name   string
+		region Region
+		a, b   Point
+		want   bool
+	}{
+		{"box: through without an endpoint inside", box, Point{-1, 0.5}, Point{2, 0.5}, true},
+		{"box: clipping a corner", box, Point{0.99, 1.001}, Point{1.001, 0.99}, true},
+		{"box: passing the corner", box, Point{0.99, 1.02}, Point{1.02, 0.99}, false},
+		{"box: across the antimeridian", seamBox, Point{179.5, 0.5}, Point{-179.5, 0.5}, true},
+		{"box: the long way round is not taken", box, Point{-170, 0.5}, Point{170, 0.5}, false},
+		{"polygon: through", square, Point{0.5, -1}, Point{0.5, 2}, true},
+		{"polygon: outside", square, Point{2, -1}, Point{2, 2}, false},
+		{"corridor: crossing the trace", corridor, Point{-0.01, 0.005}, Point{0.01, 0.005}, true},
+		{"corridor: within the buffer", corridor, Point{0.0005, 0.0105}, Point{0.0005, 0.03}, true},
+		{"corridor: beyond the buffer", corridor, Point{0.002, 0}, Point{0.002, 0.01}, false},
+		{"union", Union{box, corridor}, Point{0.0005, 0.0105}, Point{0.0005, 0.03}, true},
+	} {
+		if got := test.region.IntersectsSegment(test.a, test.b); got != test.want {
+			t.Errorf("%s: %v = %v, want %v", test.name, []Point{test.a, test.b}, got, test.want)
+		}
+	}
+}
+
+func TestExtractKeepsEdgesClippingACorner(t *testing.T) {
+	g := NewGraph()
+	a, _ := g.AddNode("a", Point{0.00099, 0.001005})
+	b, _ := g.AddNode("b", Point{0.001005, 0.00099})
+	if _, err := g.AddEdge("ab", a, b, 10, []Point{a.Position, b.Position}); err != nil {
+		t.Fatal(err)
+	}
+	extracted, err := Extract(g, NewBoundingBox(Point{0, 0}, Point{0.001, 0.001}), ExtractKeep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extracted.Edges["ab"] == nil {
+		t.Errorf("edge clipping the corner of the box by half a metre was dropped")
+	}
+}
+
+func TestExtractFindsTheSameEdgesThroughEitherIndex(t *testing.T) {
+	g := NewGridGraph(10, 0.001)
+	g.Edges["0,0>0,1"].Isolated = true
+	regions := map[string]Region{
+		"box":      NewBoundingBox(Point{0.0025, 0.0025}, Point{0.0055, 0.0045}),
+		"polygon":  Polygon{{0.002, 0.002}, {0.006, 0.003}, {0.003, 0.007}},
+		"corridor": &Corridor{Trace: []Point{{0, 0}, {0.004, 0.004}}, Buffer: 60},
+	}
+
+	edges, nodes, longest := make([]*Edge, 0, len(g.Edges)), make([]*SegmentNode, 0), 0.0
+	for _, edge := range g.sortedEdges() {
+		if !edge.Isolated {
+			edges, longest = append(edges, edge), max(longest, edge.Length)
+			for _, point := range edge.Poly {
+				nodes = append(nodes, &SegmentNode{Point: point, Edge: edge})
+			}
+		}
+	}
+	segment := NewSegment2D(nodes)
+	segment.Padding = longest / 2
+
+	for name, region := range regions {
+		for _, mode := range []string{ExtractKeep, ExtractClip} {
+			g.Index = nil
+			want, err := Extract(g, region, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want.Edges["0,0>0,1"] == nil && name == "corridor" {
+				t.Errorf("%s %s: the isolated edge was not extracted", name, mode)
+			}
+			for _, index := range []SpatialIndex{segment, NewRTree(edges)} {
+				g.Index = index
+				got, err := Extract(g, region, mode)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got.Edges) != len(want.Edges) || len(got.Nodes) != len(want.Nodes) {
+					t.Errorf("%s %s with %T: %d edges and %d nodes, want %d and %d", name, mode, index, len(got.Edges), len(got.Nodes), len(want.Edges), len(want.Nodes))
+				}
+				for id := range want.Edges {
+					if got.Edges[id] == nil {
+						t.Errorf("%s %s with %T: missing %s", name, mode, index, id)
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,7 +1,16 @@
 package pkg
 
 import (
+	"cmp"
 	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	ReverseSuffix = "_reverse"
 )
 
 var (
@@ -15,7 +24,8 @@ var (
 type dijkstraData struct {
 	MaxDuration float64
 	Distances   map[*Node]float64
-	Parents     map[*Node]*Node
+	Lengths     map[*Node]float64
+	Parents     map[*Node]*Edge
 	Visited     map[*Node]bool
 	Queue       *Heap
 }
@@ -23,18 +33,23 @@ type dijkstraData struct {
 type Node struct {
 	ID       string                 `json:"id"`
 	Position Point                  `json:"position"`
-	InEdges  map[*Node]*Edge        `json:"-"`
-	OutEdges map[*Node]*Edge        `json:"-"`
+	InEdges  map[*Node][]*Edge      `json:"-"`
+	OutEdges map[*Node][]*Edge      `json:"-"`
 	Data     map[bool]*dijkstraData `json:"-"`
 }
 
 type Edge struct {
-	ID     string  `json:"id"`
-	Start  string  `json:"start"`
-	End    string  `json:"end"`
-	Speed  float64 `json:"speed"`
-	Poly   []Point `json:"polygon"`
-	Length float64 `json:"length"`
+	ID         string        `json:"id"`
+	Start      string        `json:"start"`
+	End        string        `json:"end"`
+	Speed      float64       `json:"speed"`
+	Poly       []Point       `json:"polygon"`
+	Length     float64       `json:"length"`
+	OSMWayID   int64         `json:"osm_way_id,omitempty"`
+	OSMNodeIDs []int64       `json:"osm_node_ids,omitempty"`
+	Isolated   bool          `json:"isolated,omitempty"`
+	Attributes *Attributes   `json:"attributes,omitempty"`
+	Profile    *SpeedProfile `json:"profile,omitempty"`
 }
 
 func NewEdge(id string, start, end *Node, speed float64, poly []Point) (edge *Edge) {
@@ -69,10 +84,34 @@ func (e *Edge) LengthFrom(point Point) float64 {
 	return e.Length - e.LengthTo(point)
 }
 
+func (e *Edge) IsReverseOf(other *Edge) bool {
+	if e.Start != other.End || e.End != other.Start {
+		return false
+	}
+	return e.ID != other.ID && strings.TrimSuffix(e.ID, ReverseSuffix) == strings.TrimSuffix(other.ID, ReverseSuffix)
+}
+
+// Graph is a directed road network. With RecordClashes set, loading keeps
+// going past the problems a validation report lists: edges whose ID is taken
+// go to Clashes and rows whose geometry cannot form an edge go to Rejected.
 type Graph struct {
-	Nodes map[string]*Node `json:"nodes"`
-	Edges map[string]*Edge `json:"edges"`
-	Seg   *Segment2D       `json:"-"`
+	Nodes         map[string]*Node `json:"nodes"`
+	Edges         map[string]*Edge `json:"edges"`
+	Index         SpatialIndex     `json:"-"`
+	Router        Router           `json:"-"`
+	RecordClashes bool             `json:"-"`
+	Clashes       []*Edge          `json:"-"`
+	Rejected      []*Edge          `json:"-"`
+	cached        []*Node
+	revision      uint64
+	topology      uint64
+	costChanged   map[*Edge]uint64
+	cost          func(*Edge) float64
+	scale         float64
+	scaleRevision uint64
+	clock         time.Time
+	overrides     map[string]*Override
+	edgeOverrides map[string][]*Override
 }
 
 func NewGraph() (graph *Graph) {
@@ -91,17 +130,16 @@ func (g *Graph) AddNode(id string, position Point) (*Node, error) {
 	g.Nodes[id] = &Node{
 		ID:       id,
 		Position: position,
-		InEdges:  make(map[*Node]*Edge),
-		OutEdges: make(map[*Node]*Edge),
+		InEdges:  make(map[*Node][]*Edge),
+		OutEdges: make(map[*Node][]*Edge),
 		Data:     make(map[bool]*dijkstraData),
 	}
 	return g.Nodes[id], nil
 }
 
+// AddEdge returns a nil edge and no error when the ID is taken and the edge
+// was recorded in Clashes instead, as InsertEdge does.
 func (g *Graph) AddEdge(id string, start, end *Node, speed float64, poly []Point) (*Edge, error) {
-	if _, ok := g.Edges[id]; ok {
-		return nil, ErrEdgeExists
-	}
 	if _, ok := g.Nodes[start.ID]; !ok {
 		return nil, ErrNodeNotFound
 	}
@@ -110,13 +148,144 @@ func (g *Graph) AddEdge(id string, start, end *Node, speed float64, poly []Point
 	}
 
 	edge := NewEdge(id, start, end, speed, poly)
-	g.Edges[id] = edge
-
-	start.OutEdges[end] = edge
-	end.InEdges[start] = edge
+	if err := g.InsertEdge(edge); err != nil {
+		return nil, err
+	}
+	if g.Edges[id] != edge {
+		return nil, nil
+	}
 	return edge, nil
 }
 
+func (g *Graph) InsertEdge(edge *Edge) error {
+	if _, ok := g.Edges[edge.ID]; ok {
+		if g.RecordClashes {
+			g.Clashes = append(g.Clashes, edge)
+			return nil
+		}
+		return ErrEdgeExists
+	}
+	start, ok := g.Nodes[edge.Start]
+	if !ok {
+		return ErrNodeNotFound
+	}
+	end, ok := g.Nodes[edge.End]
+	if !ok {
+		return ErrNodeNotFound
+	}
+
+	g.Edges[edge.ID] = edge
+	start.OutEdges[end] = append(start.OutEdges[end], edge)
+	end.InEdges[start] = append(end.InEdges[start], edge)
+
+	if g.Index != nil && !edge.Isolated {
+		g.Index.Insert(edge)
+	}
+	g.resetCache()
+	return nil
+}
+
+func (g *Graph) RemoveEdge(id string) error {
+	edge, ok := g.Edges[id]
+	if !ok {
+		return ErrEdgeNotFound
+	}
+	delete(g.Edges, id)
+
+	start, end := g.Nodes[edge.Start], g.Nodes[edge.End]
+	removeAdjacent(start.OutEdges, end, edge)
+	removeAdjacent(end.InEdges, start, edge)
+
+	if g.Index != nil {
+		g.Index.Remove(edge)
+	}
+	delete(g.costChanged, edge)
+	g.resetCache()
+	return nil
+}
+
+func removeAdjacent(adjacent map[*Node][]*Edge, node *Node, edge *Edge) {
+	edges := slices.DeleteFunc(adjacent[node], func(e *Edge) bool { return e == edge })
+	if len(edges) == 0 {
+		delete(adjacent, node)
+	} else {
+		adjacent[node] = edges
+	}
+}
+
+func (g *Graph) ParallelEdges() (groups [][]*Edge) {
+	for _, node := range g.Nodes {
+		for _, edges := range node.OutEdges {
+			if len(edges) > 1 {
+				groups = append(groups, slices.Clone(edges))
+			}
+		}
+	}
+	slices.SortFunc(groups, func(a, b []*Edge) int { return cmp.Compare(a[0].ID, b[0].ID) })
+	return
+}
+
+func (g *Graph) resetCache() {
+	for _, node := range g.cached {
+		node.Data = make(map[bool]*dijkstraData)
+	}
+	g.cached = nil
+	g.revision++
+	g.topology = g.revision
+}
+
+func (g *Graph) SetCost(cost func(*Edge) float64) {
+	g.cost = cost
+	g.resetCache()
+}
+
+func (g *Graph) baseCost(edge *Edge) float64 {
+	if g.cost != nil {
+		return g.cost(edge)
+	}
+	return edge.Length
+}
+
+// EdgeCost applies the overrides active at the graph clock to the base cost:
+// a speed override scales it by how much slower or faster the edge becomes and
+// a penalty is added on top.
+func (g *Graph) EdgeCost(edge *Edge) float64 {
+	cost := g.baseCost(edge)
+	if override, ok := g.effectiveOverride(edge, g.clock); ok {
+		if override.Disabled {
+			return math.Inf(1)
+		}
+		if override.Speed > 0 && edge.Speed > 0 {
+			cost *= edge.Speed / override.Speed
+		}
+		cost += override.Penalty
+	}
+	return cost
+}
+
+// costScale bounds the cost per metre of any edge before overrides, so a search
+// horizon given in metres covers every path of that length.
+func (g *Graph) costScale() float64 {
+	if g.scale > 0 && g.scaleRevision == g.topology {
+		return g.scale
+	}
+	scale := 1.0
+	for _, edge := range g.Edges {
+		if ratio := g.baseCost(edge) / edge.Length; edge.Length > 0 && ratio > scale && !math.IsInf(ratio, 1) {
+			scale = ratio
+		}
+	}
+	g.scale, g.scaleRevision = scale, g.topology
+	return scale
+}
+
+func (g *Graph) Routing() Router {
+	if g.Router != nil {
+		return g.Router
+	}
+	return g
+}
+
 func (g *Graph) GetNode(id string) (*Node, error) {
 	node, ok := g.Nodes[id]
 	if !ok {
@@ -134,6 +303,7 @@ func (g *Graph) GetEdge(id string) (*Edge, error) {
 }
 
 func (g *Graph) getData(node *Node, maxDuration float64, reverse bool) *dijkstraData {
+	maxDuration *= g.costScale()
 	if data, ok := node.Data[reverse]; !ok {
 		g.dijkstra(node, maxDuration, reverse)
 	} else if data.MaxDuration < maxDuration {
@@ -144,8 +314,8 @@ func (g *Graph) getData(node *Node, maxDuration float64, reverse bool) *dijkstra
 
 func (g *Graph) GetDistance(start, end *Node, maxDuration float64, reverse bool) (float64, error) {
 	data := g.getData(start, maxDuration, reverse)
-	if distance, ok := data.Distances[end]; ok {
-		return distance, nil
+	if _, ok := data.Distances[end]; ok {
+		return data.Lengths[end], nil
 	}
 	return -1, ErrNodeNotReachable
 }
@@ -157,16 +327,42 @@ func (g *Graph) GetBestPath(start, end *Node, maxDuration float64, reverse bool)
 	}
 
 	path := make([]*Edge, 0)
-	for current := end; current != start; current = data.Parents[current] {
+	for current := end; current != start; {
+		edge := data.Parents[current]
+		path = append(path, edge)
 		if reverse {
-			path = append(path, current.OutEdges[data.Parents[current]])
+			current = g.Nodes[edge.End]
 		} else {
-			path = append(path, current.InEdges[data.Parents[current]])
+			current = g.Nodes[edge.Start]
 		}
 	}
 	return path, nil
 }
 
+func (g *Graph) Distance(start, end string, maxDistance float64, reverse bool) (float64, error) {
+	from, err := g.GetNode(start)
+	if err != nil {
+		return -1, err
+	}
+	to, err := g.GetNode(end)
+	if err != nil {
+		return -1, err
+	}
+	return g.GetDistance(from, to, maxDistance, reverse)
+}
+
+func (g *Graph) Path(start, end string, maxDistance float64, reverse bool) ([]*Edge, error) {
+	from, err := g.GetNode(start)
+	if err != nil {
+		return nil, err
+	}
+	to, err := g.GetNode(end)
+	if err != nil {
+		return nil, err
+	}
+	return g.GetBestPath(from, to, maxDistance, reverse)
+}
+
 type heapNode struct {
 	node     *Node
 	distance float64
@@ -174,10 +370,14 @@ type heapNode struct {
 
 func (g *Graph) dijkstra(start *Node, maxDuration float64, reverse bool) {
 	if data, ok := start.Data[reverse]; !ok {
+		if len(start.Data) == 0 {
+			g.cached = append(g.cached, start)
+		}
 		start.Data[reverse] = &dijkstraData{
 			MaxDuration: maxDuration,
 			Distances:   make(map[*Node]float64),
-			Parents:     make(map[*Node]*Node),
+			Lengths:     make(map[*Node]float64),
+			Parents:     make(map[*Node]*Edge),
 			Visited:     make(map[*Node]bool),
 			Queue: NewHeap(func(i, j interface{}) bool {
 				if i.(heapNode).distance == j.(heapNode).distance {
@@ -189,6 +389,7 @@ func (g *Graph) dijkstra(start *Node, maxDuration float64, reverse bool) {
 		}
 
 		start.Data[reverse].Distances[start] = 0
+		start.Data[reverse].Lengths[start] = 0
 		start.Data[reverse].Queue.Push(heapNode{node: start, distance: 0})
 	} else if data.MaxDuration < maxDuration {
 		data.MaxDuration = maxDuration
@@ -196,10 +397,8 @@ func (g *Graph) dijkstra(start *Node, maxDuration float64, reverse bool) {
 		return
 	}
 
-	priorityQueue := start.Data[reverse].Queue
-	visited := start.Data[reverse].Visited
-	dist := start.Data[reverse].Distances
-	par := start.Data[reverse].Parents
+	data := start.Data[reverse]
+	priorityQueue, visited := data.Queue, data.Visited
 
 	for priorityQueue.Length() > 0 {
 		current := priorityQueue.Pop().(heapNode)
@@ -211,22 +410,29 @@ func (g *Graph) dijkstra(start *Node, maxDuration float64, reverse bool) {
 		}
 		visited[current.node] = true
 
-		g.updateDistances(current, priorityQueue, visited, dist, par, reverse)
+		g.updateDistances(current, data, reverse)
 	}
 }
 
-func (g *Graph) updateDistances(current heapNode, priorityQueue *Heap, visited map[*Node]bool, dist map[*Node]float64, par map[*Node]*Node, reverse bool) {
+func (g *Graph) updateDistances(current heapNode, data *dijkstraData, reverse bool) {
 	edges := current.node.OutEdges
 	if reverse {
 		edges = current.node.InEdges
 	}
 
-	for neighbour, edge := range edges {
-		if !visited[neighbour] {
-			distance := current.distance + edge.Length
-			if current_distance, ok := dist[neighbour]; !ok || distance < current_distance {
-				dist[neighbour], par[neighbour] = distance, current.node
-				priorityQueue.Push(heapNode{node: neighbour, distance: distance})
+	for neighbour, parallel := range edges {
+		if data.Visited[neighbour] {
+			continue
+		}
+		for _, edge := range parallel {
+			distance := current.distance + g.EdgeCost(edge)
+			if math.IsInf(distance, 1) {
+				continue
+			}
+			if current_distance, ok := data.Distances[neighbour]; !ok || distance < current_distance {
+				data.Distances[neighbour], data.Parents[neighbour] = distance, edge
+				data.Lengths[neighbour] = data.Lengths[current.node] + edge.Length
+				data.Queue.Push(heapNode{node: neighbour, distance: distance})
 			}
 		}
 	}
@@ -0,0 +1,31 @@
+package pkg
+
+import "fmt"
+
+// NewGridGraph lays out size×size nodes named "row,column" spacing degrees
+// apart and links neighbours with two-way streets whose speeds vary between
+// 10 and 14 m/s. It is the synthetic network used by the tests and
+// benchmarks.
+func NewGridGraph(size int, spacing float64) *Graph {
+	g := NewGraph()
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			g.AddNode(fmt.Sprintf("%d,%d", i, j), Point{Longitude: float64(j) * spacing, Latitude: float64(i) * spacing})
+		}
+	}
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			start := g.Nodes[fmt.Sprintf("%d,%d", i, j)]
+			for k, next := range [][2]int{{i, j + 1}, {i + 1, j}} {
+				end, ok := g.Nodes[fmt.Sprintf("%d,%d", next[0], next[1])]
+				if !ok {
+					continue
+				}
+				speed := float64(10 + (i+j+k)%5)
+				g.AddEdge(start.ID+">"+end.ID, start, end, speed, []Point{start.Position, end.Position})
+				g.AddEdge(end.ID+">"+start.ID, end, start, speed, []Point{end.Position, start.Position})
+			}
+		}
+	}
+	return g
+}
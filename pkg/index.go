@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"cmp"
+	"slices"
+)
+
+type Candidate struct {
+	Edge     *Edge   `json:"edge"`
+	Point    Point   `json:"point"`
+	Distance float64 `json:"distance"`
+}
+
+type SpatialIndex interface {
+	Nearest(point Point, k int) []Candidate
+	WithinRadius(point Point, meters float64) []Candidate
+	// Intersecting returns, in no particular order, the edges that may cross
+	// box: every edge that does, and possibly a few nearby ones.
+	Intersecting(box BoundingBox) []*Edge
+	Insert(edge *Edge)
+	Remove(edge *Edge)
+}
+
+func sortCandidates(candidates []Candidate) {
+	slices.SortFunc(candidates, func(a, b Candidate) int {
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Edge.ID, b.Edge.ID)
+	})
+}
@@ -0,0 +1,30 @@
+package pkg
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIndexesFindEdgesAcrossTheAntimeridian(t *testing.T) {
+	seam := segmentEdge("seam", Point{179.999, 10}, Point{-179.999, 10})
+	east := segmentEdge("east", Point{179.9, 10}, Point{179.901, 10})
+	west := segmentEdge("west", Point{-179.9, 10}, Point{-179.901, 10})
+	segment := segmentIndex(seam, east, west)
+	segment.Padding = seam.Poly[0].Distance(seam.Poly[1]) / 2
+	indexes := map[string]SpatialIndex{"segment": segment, "rtree": NewRTree([]*Edge{seam, east, west})}
+
+	for name, index := range indexes {
+		for _, point := range []Point{{180, 10.0001}, {-180, 9.9999}, {179.9995, 10}, {-179.9995, 10}} {
+			if got := candidateIDs(index.WithinRadius(point, 50)); !slices.Equal(got, []string{"seam"}) {
+				t.Errorf("%s: within 50m of %v %v, want [seam]", name, point, got)
+			}
+			if got := candidateIDs(index.Nearest(point, 1)); !slices.Equal(got, []string{"seam"}) {
+				t.Errorf("%s: nearest to %v %v, want [seam]", name, point, got)
+			}
+		}
+		// Ranking measures across the seam, so east is 0.17° away rather than 359.8°.
+		if got := candidateIDs(index.Nearest(Point{-179.93, 10}, 3)); !slices.Equal(got, []string{"west", "seam", "east"}) {
+			t.Errorf("%s: nearest to -179.93 %v, want [west seam east]", name, got)
+		}
+	}
+}
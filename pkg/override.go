@@ -0,0 +1,225 @@
+package pkg
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidOverride  = errors.New("invalid override")
+	ErrOverrideNotFound = errors.New("override not found")
+)
+
+type Override struct {
+	ID       string     `json:"id"`
+	Edge     string     `json:"edge"`
+	Disabled bool       `json:"disabled,omitempty"`
+	Speed    float64    `json:"speed,omitempty"`
+	Penalty  float64    `json:"penalty,omitempty"`
+	From     *time.Time `json:"from,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+func (o *Override) Active(t time.Time) bool {
+	return (o.From == nil || !t.Before(*o.From)) && (o.Until == nil || t.Before(*o.Until))
+}
+
+func (o *Override) Validate() error {
+	switch {
+	case o.ID == "" || o.Edge == "":
+		return fmt.Errorf("%w: id and edge are required", ErrInvalidOverride)
+	case math.IsNaN(o.Speed) || math.IsInf(o.Speed, 0) || o.Speed < 0:
+		return fmt.Errorf("%w: speed must be finite and non-negative", ErrInvalidOverride)
+	case math.IsNaN(o.Penalty) || math.IsInf(o.Penalty, 0) || o.Penalty < 0:
+		return fmt.Errorf("%w: penalty must be finite and non-negative", ErrInvalidOverride)
+	case o.From != nil && o.Until != nil && !o.From.Before(*o.Until):
+		return fmt.Errorf("%w: from must be before until", ErrInvalidOverride)
+	}
+	return nil
+}
+
+type edgeOverride struct {
+	Disabled bool
+	Speed    float64
+	Penalty  float64
+}
+
+func (g *Graph) effectiveOverride(edge *Edge, t time.Time) (result edgeOverride, ok bool) {
+	for _, override := range g.edgeOverrides[edge.ID] {
+		if !override.Active(t) {
+			continue
+		}
+		ok = true
+		result.Disabled = result.Disabled || override.Disabled
+		result.Penalty += override.Penalty
+		if override.Speed > 0 {
+			result.Speed = override.Speed
+		}
+	}
+	return
+}
+
+func (g *Graph) SetOverride(override Override) error {
+	if err := override.Validate(); err != nil {
+		return err
+	}
+	edge, ok := g.Edges[override.Edge]
+	if !ok {
+		return ErrEdgeNotFound
+	}
+
+	affected := []*Edge{edge}
+	if previous, ok := g.overrides[override.ID]; ok {
+		g.dropOverride(previous)
+		if other, ok := g.Edges[previous.Edge]; ok && other != edge {
+			affected = append(affected, other)
+		}
+	}
+	if g.overrides == nil {
+		g.overrides, g.edgeOverrides = make(map[string]*Override), make(map[string][]*Override)
+	}
+
+	g.overrides[override.ID] = &override
+	g.edgeOverrides[override.Edge] = append(g.edgeOverrides[override.Edge], &override)
+	slices.SortFunc(g.edgeOverrides[override.Edge], func(a, b *Override) int { return cmp.Compare(a.ID, b.ID) })
+	g.invalidate(affected)
+	return nil
+}
+
+func (g *Graph) RemoveOverride(id string) error {
+	override, ok := g.overrides[id]
+	if !ok {
+		return ErrOverrideNotFound
+	}
+	g.dropOverride(override)
+	if edge, ok := g.Edges[override.Edge]; ok {
+		g.invalidate([]*Edge{edge})
+	}
+	return nil
+}
+
+func (g *Graph) dropOverride(override *Override) {
+	delete(g.overrides, override.ID)
+	edges := slices.DeleteFunc(g.edgeOverrides[override.Edge], func(o *Override) bool { return o == override })
+	if len(edges) == 0 {
+		delete(g.edgeOverrides, override.Edge)
+	} else {
+		g.edgeOverrides[override.Edge] = edges
+	}
+}
+
+func (g *Graph) ClearOverrides() {
+	affected := make([]*Edge, 0, len(g.edgeOverrides))
+	for id := range g.edgeOverrides {
+		if edge, ok := g.Edges[id]; ok {
+			affected = append(affected, edge)
+		}
+	}
+	g.overrides, g.edgeOverrides = nil, nil
+	g.invalidate(affected)
+}
+
+func (g *Graph) Overrides() []Override {
+	overrides := make([]Override, 0, len(g.overrides))
+	for _, override := range g.overrides {
+		overrides = append(overrides, *override)
+	}
+	slices.SortFunc(overrides, func(a, b Override) int { return cmp.Compare(a.ID, b.ID) })
+	return overrides
+}
+
+func (g *Graph) SetClock(t time.Time) {
+	affected := make([]*Edge, 0)
+	for id, overrides := range g.edgeOverrides {
+		edge, ok := g.Edges[id]
+		if !ok {
+			continue
+		}
+		for _, override := range overrides {
+			if override.Active(g.clock) != override.Active(t) {
+				affected = append(affected, edge)
+				break
+			}
+		}
+	}
+	g.clock = t
+	if len(affected) > 0 {
+		g.invalidate(affected)
+	}
+}
+
+func (g *Graph) Clock() time.Time {
+	return g.clock
+}
+
+func (g *Graph) Disabled(edge *Edge) bool {
+	return g.DisabledAt(edge, g.clock)
+}
+
+func (g *Graph) DisabledAt(edge *Edge, t time.Time) bool {
+	override, _ := g.effectiveOverride(edge, t)
+	return override.Disabled
+}
+
+func (g *Graph) SpeedAt(edge *Edge, t time.Time) float64 {
+	if override, ok := g.effectiveOverride(edge, t); ok {
+		if override.Disabled {
+			return 0
+		}
+		if override.Speed > 0 {
+			return override.Speed
+		}
+	}
+	return edge.SpeedAt(t)
+}
+
+func (g *Graph) TravelTime(edge *Edge, departure time.Time) float64 {
+	override, ok := g.effectiveOverride(edge, departure)
+	if !ok {
+		return edge.TravelTime(departure)
+	} else if override.Disabled {
+		return math.Inf(1)
+	}
+
+	travelTime := edge.TravelTime(departure)
+	if override.Speed > 0 {
+		travelTime = edge.Length / override.Speed
+	}
+	if speed := g.SpeedAt(edge, departure); override.Penalty > 0 && speed > 0 {
+		travelTime += override.Penalty / speed
+	}
+	return travelTime
+}
+
+// invalidate drops the cached searches that already relaxed one of the edges:
+// a forward search relaxes an edge once its start is visited, a reverse one
+// once its end is. The edges are recorded so CSR routers patch their costs.
+func (g *Graph) invalidate(edges []*Edge) {
+	for _, node := range g.cached {
+		for reverse, data := range node.Data {
+			for _, edge := range edges {
+				endpoint := edge.Start
+				if reverse {
+					endpoint = edge.End
+				}
+				if data.Visited[g.Nodes[endpoint]] {
+					delete(node.Data, reverse)
+					break
+				}
+			}
+		}
+	}
+	g.cached = slices.DeleteFunc(g.cached, func(node *Node) bool { return len(node.Data) == 0 })
+	g.revision++
+
+	if g.costChanged == nil {
+		g.costChanged = make(map[*Edge]uint64)
+	}
+	for _, edge := range edges {
+		g.costChanged[edge] = g.revision
+	}
+}
@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// overrideGraph is a diamond a→b→c / a→d→c where the b branch is shorter,
+// plus an unrelated edge x→y.
+func overrideGraph(t *testing.T) *Graph {
+	t.Helper()
+	g := NewGraph()
+	positions := map[string]Point{
+		"a": {Longitude: 0, Latitude: 0},
+		"b": {Longitude: 0.001, Latitude: 0},
+		"c": {Longitude: 0.002, Latitude: 0},
+		"d": {Longitude: 0.001, Latitude: 0.001},
+		"x": {Longitude: 1, Latitude: 1},
+		"y": {Longitude: 1.001, Latitude: 1},
+	}
+	for id, position := range positions {
+		if _, err := g.AddNode(id, position); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, edge := range [][2]string{{"a", "b"}, {"b", "c"}, {"a", "d"}, {"d", "c"}, {"x", "y"}} {
+		start, end := g.Nodes[edge[0]], g.Nodes[edge[1]]
+		if _, err := g.AddEdge(edge[0]+edge[1], start, end, 10, []Point{start.Position, end.Position}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+func pathIDs(t *testing.T, router Router, start, end string) []string {
+	t.Helper()
+	path, err := router.Path(start, end, 0, false)
+	if err != nil {
+		t.Fatalf("path %s→%s: %v", start, end, err)
+	}
+	ids := make([]string, len(path))
+	for i, edge := range path {
+		ids[len(path)-1-i] = edge.ID
+	}
+	return ids
+}
+
+func distance(t *testing.T, router Router, start, end string) float64 {
+	t.Helper()
+	d, err := router.Distance(start, end, 0, false)
+	if err != nil {
+		t.Fatalf("distance %s→%s: %v", start, end, err)
+	}
+	return d
+}
+
+func TestOverrideInvalidatesOnlyAffectedSearches(t *testing.T) {
+	g := overrideGraph(t)
+	direct := g.Edges["ab"].Length + g.Edges["bc"].Length
+	detour := g.Edges["ad"].Length + g.Edges["dc"].Length
+
+	if d := distance(t, g, "a", "c"); math.Abs(d-direct) > 1e-9 {
+		t.Fatalf("a→c = %v, want %v", d, direct)
+	}
+	distance(t, g, "x", "y")
+	if _, err := g.Distance("a", "c", 0, true); err == nil {
+		t.Fatal("reverse search from a should not reach c")
+	}
+	relaxed, unrelated, reverse := g.Nodes["a"].Data[false], g.Nodes["x"].Data[false], g.Nodes["a"].Data[true]
+
+	if err := g.SetOverride(Override{ID: "closure", Edge: "bc", Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.Nodes["a"].Data[false]; ok {
+		t.Error("forward search from a relaxed bc but was kept")
+	}
+	if g.Nodes["x"].Data[false] != unrelated {
+		t.Error("search from x never reached bc but was dropped")
+	}
+	if g.Nodes["a"].Data[true] != reverse {
+		t.Error("reverse search from a never visited c but was dropped")
+	}
+
+	if d := distance(t, g, "a", "c"); math.Abs(d-detour) > 1e-9 {
+		t.Errorf("a→c with bc closed = %v, want %v", d, detour)
+	}
+	if g.Nodes["a"].Data[false] == relaxed {
+		t.Error("a→c was answered from the stale search")
+	}
+
+	if err := g.RemoveOverride("closure"); err != nil {
+		t.Fatal(err)
+	}
+	if d := distance(t, g, "a", "c"); math.Abs(d-direct) > 1e-9 {
+		t.Errorf("a→c after reopening bc = %v, want %v", d, direct)
+	}
+	if err := g.RemoveOverride("closure"); !errors.Is(err, ErrOverrideNotFound) {
+		t.Errorf("removing twice: %v, want %v", err, ErrOverrideNotFound)
+	}
+}
+
+func TestOverridePenaltyOnlyPicksThePath(t *testing.T) {
+	g := overrideGraph(t)
+	if err := g.SetOverride(Override{ID: "slow", Edge: "ab", Penalty: 10000}); err != nil {
+		t.Fatal(err)
+	}
+	detour := g.Edges["ad"].Length + g.Edges["dc"].Length
+	if d := distance(t, g, "a", "c"); math.Abs(d-detour) > 1e-9 {
+		t.Errorf("a→c = %v, want the metric length of the detour %v", d, detour)
+	}
+}
+
+func TestOverrideSpeedChangesTheRoutingCost(t *testing.T) {
+	g := overrideGraph(t)
+	c := NewCSRGraph(g)
+	if err := g.SetOverride(Override{ID: "jam", Edge: "ab", Speed: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if cost, want := g.EdgeCost(g.Edges["ab"]), g.Edges["ab"].Length*5; math.Abs(cost-want) > 1e-9 {
+		t.Errorf("cost of ab at a fifth of its speed %v, want %v", cost, want)
+	}
+	for _, router := range []Router{g, c} {
+		if got := pathIDs(t, router, "a", "c"); !slices.Equal(got, []string{"ad", "dc"}) {
+			t.Errorf("%T: path with ab slowed down %v, want [ad dc]", router, got)
+		}
+	}
+
+	if err := g.SetOverride(Override{ID: "jam", Edge: "ab", Speed: 20}); err != nil {
+		t.Fatal(err)
+	}
+	for _, router := range []Router{g, c} {
+		if got := pathIDs(t, router, "a", "c"); !slices.Equal(got, []string{"ab", "bc"}) {
+			t.Errorf("%T: path with ab sped up %v, want [ab bc]", router, got)
+		}
+	}
+}
+
+func TestSetClockAcrossWindowReroutes(t *testing.T) {
+	g := overrideGraph(t)
+	from := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	until := from.Add(time.Hour)
+	if err := g.SetOverride(Override{ID: "works", Edge: "bc", Disabled: true, From: &from, Until: &until}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []struct {
+		clock time.Time
+		want  []string
+	}{
+		{from.Add(-time.Minute), []string{"ab", "bc"}},
+		{from, []string{"ad", "dc"}},
+		{until.Add(-time.Nanosecond), []string{"ad", "dc"}},
+		{until, []string{"ab", "bc"}},
+	} {
+		g.SetClock(step.clock)
+		if got := pathIDs(t, g, "a", "c"); !slices.Equal(got, step.want) {
+			t.Errorf("at %s: path %v, want %v", step.clock.Format(time.RFC3339Nano), got, step.want)
+		}
+	}
+
+	distance(t, g, "x", "y")
+	unrelated := g.Nodes["x"].Data[false]
+	g.SetClock(from)
+	if g.Nodes["x"].Data[false] != unrelated {
+		t.Error("crossing the window dropped a search that never reached bc")
+	}
+}
+
+func TestCSRRouterFollowsOverrides(t *testing.T) {
+	g := overrideGraph(t)
+	c := NewCSRGraph(g)
+	if got := pathIDs(t, c, "a", "c"); !slices.Equal(got, []string{"ab", "bc"}) {
+		t.Fatalf("path %v, want [ab bc]", got)
+	}
+
+	revision, offsets := c.revision, c.OutOffsets
+	if err := g.SetOverride(Override{ID: "closure", Edge: "bc", Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := pathIDs(t, c, "a", "c"); !slices.Equal(got, []string{"ad", "dc"}) {
+		t.Errorf("path with bc closed %v, want [ad dc]", got)
+	}
+	if c.revision == revision {
+		t.Error("CSR router did not refresh after the override changed")
+	}
+	if &c.OutOffsets[0] != &offsets[0] {
+		t.Error("CSR router was rebuilt for an override instead of patching the edge cost")
+	}
+
+	g.ClearOverrides()
+	if got := pathIDs(t, c, "a", "c"); !slices.Equal(got, []string{"ab", "bc"}) {
+		t.Errorf("path after clearing %v, want [ab bc]", got)
+	}
+
+	if err := g.SetOverride(Override{ID: "closure", Edge: "dc", Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetOverride(Override{ID: "closure2", Edge: "bc", Disabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Distance("a", "c", 0, false); !errors.Is(err, ErrNodeNotReachable) {
+		t.Errorf("a→c with both branches closed: %v, want %v", err, ErrNodeNotReachable)
+	}
+}
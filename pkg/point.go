@@ -22,32 +22,64 @@ func toDegrees(rad float64) float64 {
 	return rad * 180 / math.Pi
 }
 
+func clamp(x, low, high float64) float64 {
+	return math.Max(low, math.Min(x, high))
+}
+
+func NormalizeLongitude(long float64) float64 {
+	long = math.Mod(long+180, 360)
+	if long < 0 {
+		long += 360
+	}
+	return long - 180
+}
+
+func longitudeDifference(from, to float64) float64 {
+	return NormalizeLongitude(to - from)
+}
+
+func (p *Point) vector() (float64, float64, float64) {
+	lat, long := toRadians(p.Latitude), toRadians(p.Longitude)
+	return math.Cos(lat) * math.Cos(long), math.Cos(lat) * math.Sin(long), math.Sin(lat)
+}
+
+func fromVector(x, y, z float64) Point {
+	return Point{
+		Latitude:  toDegrees(math.Atan2(z, math.Hypot(x, y))),
+		Longitude: toDegrees(math.Atan2(y, x)),
+	}
+}
+
 func (p *Point) Distance(other Point) float64 {
 	lat1, long1 := toRadians(p.Latitude), toRadians(p.Longitude)
 	lat2, long2 := toRadians(other.Latitude), toRadians(other.Longitude)
 
 	dlat, dlong := lat2-lat1, long2-long1
 	a := math.Pow(math.Sin(dlat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dlong/2), 2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(math.Max(0, 1-a)))
 
 	return c * EarthRadius
 }
 
 func (p *Point) Move(dx, dy float64) Point {
+	lat := p.Latitude + toDegrees(dy/EarthRadius)
+	long := p.Longitude + toDegrees(dx/(EarthRadius*math.Max(math.Cos(toRadians(p.Latitude)), Epsilon)))
+	if lat > 90 {
+		lat, long = 180-lat, long+180
+	} else if lat < -90 {
+		lat, long = -180-lat, long+180
+	}
+
 	return Point{
-		Longitude: p.Longitude + toDegrees(dx/(EarthRadius*math.Cos(toRadians(p.Latitude)))),
-		Latitude:  p.Latitude + toDegrees(dy/EarthRadius),
+		Longitude: NormalizeLongitude(long),
+		Latitude:  lat,
 	}
 }
 
 func (p *Point) ClosestPointOnSegment(a, b Point) Point {
-	latP, longP := toRadians(p.Latitude), toRadians(p.Longitude)
-	latA, longA := toRadians(a.Latitude), toRadians(a.Longitude)
-	latB, longB := toRadians(b.Latitude), toRadians(b.Longitude)
-
-	xP, yP, zP := math.Cos(latP)*math.Cos(longP), math.Cos(latP)*math.Sin(longP), math.Sin(latP)
-	xA, yA, zA := math.Cos(latA)*math.Cos(longA), math.Cos(latA)*math.Sin(longA), math.Sin(latA)
-	xB, yB, zB := math.Cos(latB)*math.Cos(longB), math.Cos(latB)*math.Sin(longB), math.Sin(latB)
+	xP, yP, zP := p.vector()
+	xA, yA, zA := a.vector()
+	xB, yB, zB := b.vector()
 
 	ABx, ABy, ABz := xB-xA, yB-yA, zB-zA
 	APx, APy, APz := xP-xA, yP-yA, zP-zA
@@ -55,19 +87,13 @@ func (p *Point) ClosestPointOnSegment(a, b Point) Point {
 	abAb := ABx*ABx + ABy*ABy + ABz*ABz
 	apAb := APx*ABx + APy*ABy + APz*ABz
 	projFactor := apAb / abAb
-	if projFactor < 0 {
+	if projFactor < 0 || abAb == 0 {
 		return a
 	} else if projFactor > 1 {
 		return b
 	}
 
-	x, y, z := xA+projFactor*ABx, yA+projFactor*ABy, zA+projFactor*ABz
-	lat, long := math.Asin(z), math.Atan2(y, x)
-
-	return Point{
-		Latitude:  toDegrees(lat),
-		Longitude: toDegrees(long),
-	}
+	return fromVector(xA+projFactor*ABx, yA+projFactor*ABy, zA+projFactor*ABz)
 }
 
 func (p *Point) IsOnSegment(a, b Point) bool {
@@ -93,14 +119,22 @@ func (p *Point) DistanceToEdge(edge *Edge) float64 {
 }
 
 func (a *Point) MoveTowards(b Point, d float64) Point {
-	latA, longA := toRadians(a.Latitude), toRadians(a.Longitude)
-	latB, longB := toRadians(b.Latitude), toRadians(b.Longitude)
-
-	latDiff, longDiff := latB-latA, longB-longA
-	lat, long := latA+latDiff*d/a.Distance(b), longA+longDiff*d/a.Distance(b)
+	distance := a.Distance(b)
+	if distance < Epsilon {
+		return *a
+	}
 
-	return Point{
-		Latitude:  toDegrees(lat),
-		Longitude: toDegrees(long),
+	angle, fraction := distance/EarthRadius, d/distance
+	xA, yA, zA := a.vector()
+	if math.Pi-angle < Epsilon {
+		// Every great circle through a reaches its antipode, so follow the meridian north.
+		lat, long := toRadians(a.Latitude), toRadians(a.Longitude)
+		xN, yN, zN := -math.Sin(lat)*math.Cos(long), -math.Sin(lat)*math.Sin(long), math.Cos(lat)
+		c, s := math.Cos(d/EarthRadius), math.Sin(d/EarthRadius)
+		return fromVector(c*xA+s*xN, c*yA+s*yN, c*zA+s*zN)
 	}
+
+	wA, wB := math.Sin((1-fraction)*angle)/math.Sin(angle), math.Sin(fraction*angle)/math.Sin(angle)
+	xB, yB, zB := b.vector()
+	return fromVector(wA*xA+wB*xB, wA*yA+wB*yB, wA*zA+wB*zB)
 }
@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func checkMoveTowards(t *testing.T, a, b Point, fraction float64) {
+	t.Helper()
+	distance := a.Distance(b)
+	moved := a.MoveTowards(b, fraction*distance)
+	if math.IsNaN(moved.Latitude) || math.IsNaN(moved.Longitude) || math.Abs(moved.Latitude) > 90 || math.Abs(moved.Longitude) > 180 {
+		t.Fatalf("%v towards %v by %v: got %v", a, b, fraction, moved)
+	}
+	if got := a.Distance(moved); math.Abs(got-fraction*distance) > 1e-3 {
+		t.Errorf("%v towards %v by %v: moved %vm, want %vm", a, b, fraction, got, fraction*distance)
+	}
+	if got := moved.Distance(b); math.Abs(got-(1-fraction)*distance) > 1e-3 {
+		t.Errorf("%v towards %v by %v: %vm left, want %vm", a, b, fraction, got, (1-fraction)*distance)
+	}
+}
+
+func TestMoveTowardsAcrossTheAntimeridian(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		a := Point{Longitude: 179.9 + random.Float64()*0.1, Latitude: random.Float64()*2 - 1}
+		b := Point{Longitude: -179.9 - random.Float64()*0.1, Latitude: random.Float64()*2 - 1}
+		checkMoveTowards(t, a, b, random.Float64())
+
+		if moved := a.MoveTowards(b, a.Distance(b)/2); math.Abs(moved.Longitude) < 179.8 {
+			t.Errorf("midpoint of %v and %v went the long way round: %v", a, b, moved)
+		}
+	}
+}
+
+func TestMoveTowardsNearThePoles(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		pole := 1.0
+		if i%2 == 1 {
+			pole = -1
+		}
+		a := Point{Longitude: random.Float64()*360 - 180, Latitude: pole * (89.9 + random.Float64()*0.1)}
+		b := Point{Longitude: random.Float64()*360 - 180, Latitude: pole * (89.9 + random.Float64()*0.1)}
+		checkMoveTowards(t, a, b, random.Float64())
+	}
+	checkMoveTowards(t, Point{Longitude: 10, Latitude: 90}, Point{Longitude: -170, Latitude: 89}, 0.5)
+}
+
+func TestMoveTowardsAntipode(t *testing.T) {
+	for _, a := range []Point{{0, 0}, {179.5, 10}, {-45, -60}, {0, 90}, {120, -90}} {
+		b := Point{Longitude: NormalizeLongitude(a.Longitude + 180), Latitude: -a.Latitude}
+		if distance := a.Distance(b); math.IsNaN(distance) {
+			t.Fatalf("distance from %v to its antipode is NaN", a)
+		}
+		for _, d := range []float64{1, 1000, math.Pi * EarthRadius / 2} {
+			moved := a.MoveTowards(b, d)
+			if math.IsNaN(moved.Latitude) || math.IsNaN(moved.Longitude) {
+				t.Fatalf("%v towards its antipode by %vm: got %v", a, d, moved)
+			}
+			if got := a.Distance(moved); math.Abs(got-d) > 1e-3 {
+				t.Errorf("%v towards its antipode by %vm: moved %vm", a, d, got)
+			}
+		}
+		if moved := a.MoveTowards(b, math.Pi*EarthRadius/2); math.Abs(moved.Distance(b)-math.Pi*EarthRadius/2) > 1e-3 {
+			t.Errorf("%v halfway to its antipode is %vm from it", a, moved.Distance(b))
+		}
+	}
+}
+
+func TestMoveOverThePole(t *testing.T) {
+	for _, start := range []Point{{Longitude: 10, Latitude: 89.99}, {Longitude: 179.5, Latitude: 89.999}, {Longitude: -30, Latitude: -89.99}} {
+		north := 1.0
+		if start.Latitude < 0 {
+			north = -1
+		}
+		moved := start.Move(0, north*2000)
+		if math.Abs(moved.Latitude) > 90 || math.Abs(moved.Longitude) > 180 {
+			t.Fatalf("%v moved 2km over the pole: got %v", start, moved)
+		}
+		if want := NormalizeLongitude(start.Longitude + 180); math.Abs(longitudeDifference(moved.Longitude, want)) > 1e-9 {
+			t.Errorf("%v moved over the pole to longitude %v, want %v", start, moved.Longitude, want)
+		}
+		if got := start.Distance(moved); math.Abs(got-2000) > 1 {
+			t.Errorf("%v moved over the pole by %vm, want 2000m", start, got)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package pkg
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+const (
+	RTreeNodeCapacity = 16
+)
+
+type rtreeEntry struct {
+	Box   BoundingBox
+	Edge  *Edge
+	Index int
+}
+
+func (e *rtreeEntry) candidate(point Point) Candidate {
+	closest := point.ClosestPointOnSegment(e.Edge.Poly[e.Index], e.Edge.Poly[e.Index+1])
+	return Candidate{
+		Edge:     e.Edge,
+		Point:    closest,
+		Distance: point.Distance(closest),
+	}
+}
+
+type rtreeNode struct {
+	Box      BoundingBox
+	Children []*rtreeNode
+	Entries  []*rtreeEntry
+}
+
+func (n *rtreeNode) isLeaf() bool {
+	return n.Children == nil
+}
+
+// RTree indexes edge segments; Size counts the indexed edges, not segments.
+type RTree struct {
+	Root *rtreeNode
+	Size int
+}
+
+func strTile[T any](items []T, box func(T) BoundingBox) (groups [][]T) {
+	leaves := (len(items) + RTreeNodeCapacity - 1) / RTreeNodeCapacity
+	slabs := int(math.Ceil(math.Sqrt(float64(leaves))))
+	slabSize := slabs * RTreeNodeCapacity
+
+	sortBy(items, func(item T) float64 {
+		x, _ := box(item).center()
+		return x
+	})
+	for i := 0; i < len(items); i += slabSize {
+		slab := items[i:min(i+slabSize, len(items))]
+		sortBy(slab, func(item T) float64 {
+			_, y := box(item).center()
+			return y
+		})
+		for j := 0; j < len(slab); j += RTreeNodeCapacity {
+			// Clipped so that growing one node cannot overwrite its neighbour.
+			groups = append(groups, slices.Clip(slab[j:min(j+RTreeNodeCapacity, len(slab))]))
+		}
+	}
+	return
+}
+
+func sortBy[T any](items []T, key func(T) float64) {
+	slices.SortStableFunc(items, func(a, b T) int {
+		return cmp.Compare(key(a), key(b))
+	})
+}
+
+func segmentEntries(edges []*Edge) (entries []*rtreeEntry) {
+	for _, edge := range edges {
+		for i := 1; i < len(edge.Poly); i++ {
+			for _, box := range NewArcBoundingBoxes(edge.Poly[i-1], edge.Poly[i]) {
+				entries = append(entries, &rtreeEntry{
+					Box:   box,
+					Edge:  edge,
+					Index: i - 1,
+				})
+			}
+		}
+	}
+	return
+}
+
+func NewRTree(edges []*Edge) *RTree {
+	sortedEdges := make([]*Edge, len(edges))
+	copy(sortedEdges, edges)
+	slices.SortFunc(sortedEdges, func(a, b *Edge) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	entries := segmentEntries(sortedEdges)
+	tree := &RTree{}
+	for _, edge := range sortedEdges {
+		if len(edge.Poly) > 1 {
+			tree.Size++
+		}
+	}
+	if len(entries) == 0 {
+		tree.Root = &rtreeNode{Entries: entries}
+		return tree
+	}
+
+	nodes := make([]*rtreeNode, 0)
+	for _, group := range strTile(entries, func(e *rtreeEntry) BoundingBox { return e.Box }) {
+		node := &rtreeNode{Box: group[0].Box, Entries: group}
+		for _, entry := range group[1:] {
+			node.Box = node.Box.Union(entry.Box)
+		}
+		nodes = append(nodes, node)
+	}
+
+	for len(nodes) > 1 {
+		parents := make([]*rtreeNode, 0)
+		for _, group := range strTile(nodes, func(n *rtreeNode) BoundingBox { return n.Box }) {
+			node := &rtreeNode{Box: group[0].Box, Children: group}
+			for _, child := range group[1:] {
+				node.Box = node.Box.Union(child.Box)
+			}
+			parents = append(parents, node)
+		}
+		nodes = parents
+	}
+
+	tree.Root = nodes[0]
+	return tree
+}
+
+func (t *RTree) search(node *rtreeNode, box BoundingBox, visit func(*rtreeEntry)) {
+	if !node.Box.Intersects(box) {
+		return
+	}
+	if node.isLeaf() {
+		for _, entry := range node.Entries {
+			if entry.Box.Intersects(box) {
+				visit(entry)
+			}
+		}
+		return
+	}
+	for _, child := range node.Children {
+		t.search(child, box, visit)
+	}
+}
+
+func (t *RTree) WithinRadius(point Point, meters float64) []Candidate {
+	best := make(map[*Edge]Candidate)
+	for _, box := range point.BoundingBoxes(meters) {
+		t.search(t.Root, box, func(entry *rtreeEntry) {
+			candidate := entry.candidate(point)
+			if current, ok := best[entry.Edge]; candidate.Distance <= meters && (!ok || candidate.Distance < current.Distance) {
+				best[entry.Edge] = candidate
+			}
+		})
+	}
+
+	candidates := make([]Candidate, 0, len(best))
+	for _, candidate := range best {
+		candidates = append(candidates, candidate)
+	}
+	sortCandidates(candidates)
+	return candidates
+}
+
+func (t *RTree) Intersecting(box BoundingBox) []*Edge {
+	edges, seen := make([]*Edge, 0), make(map[*Edge]bool)
+	t.search(t.Root, box, func(entry *rtreeEntry) {
+		if !seen[entry.Edge] {
+			seen[entry.Edge] = true
+			edges = append(edges, entry.Edge)
+		}
+	})
+	return edges
+}
+
+type rtreeQueueItem struct {
+	node      *rtreeNode
+	candidate *Candidate
+	distance  float64
+}
+
+func (t *RTree) Nearest(point Point, k int) []Candidate {
+	candidates := make([]Candidate, 0, k)
+	if k <= 0 || t.Size == 0 {
+		return candidates
+	}
+
+	seen := make(map[*Edge]bool)
+	queue := NewHeap(func(a, b interface{}) bool {
+		return a.(rtreeQueueItem).distance < b.(rtreeQueueItem).distance
+	})
+	queue.Push(rtreeQueueItem{node: t.Root, distance: t.Root.Box.Distance(point)})
+
+	for queue.Length() > 0 && len(candidates) < k {
+		item := queue.Pop().(rtreeQueueItem)
+		switch {
+		case item.candidate != nil:
+			if !seen[item.candidate.Edge] {
+				seen[item.candidate.Edge] = true
+				candidates = append(candidates, *item.candidate)
+			}
+		case item.node.isLeaf():
+			for _, entry := range item.node.Entries {
+				if !seen[entry.Edge] {
+					candidate := entry.candidate(point)
+					queue.Push(rtreeQueueItem{candidate: &candidate, distance: candidate.Distance})
+				}
+			}
+		default:
+			for _, child := range item.node.Children {
+				queue.Push(rtreeQueueItem{node: child, distance: child.Box.Distance(point)})
+			}
+		}
+	}
+	return candidates
+}
+
+func (t *RTree) contains(edge *Edge, box BoundingBox) (found bool) {
+	if t.Size == 0 {
+		return false
+	}
+	t.search(t.Root, box, func(entry *rtreeEntry) {
+		found = found || entry.Edge == edge
+	})
+	return
+}
+
+func (t *RTree) Insert(edge *Edge) {
+	entries := segmentEntries([]*Edge{edge})
+	if len(entries) == 0 || t.contains(edge, entries[0].Box) {
+		return
+	}
+	for i, entry := range entries {
+		if t.Size == 0 && i == 0 {
+			t.Root = &rtreeNode{Box: entry.Box, Entries: []*rtreeEntry{entry}}
+		} else if sibling := t.Root.insert(entry); sibling != nil {
+			t.Root = &rtreeNode{Box: t.Root.Box.Union(sibling.Box), Children: []*rtreeNode{t.Root, sibling}}
+		}
+	}
+	t.Size++
+}
+
+func (n *rtreeNode) insert(entry *rtreeEntry) *rtreeNode {
+	n.Box = n.Box.Union(entry.Box)
+	if n.isLeaf() {
+		n.Entries = append(n.Entries, entry)
+		if len(n.Entries) <= RTreeNodeCapacity {
+			return nil
+		}
+		return n.splitEntries()
+	}
+
+	best, bestGrowth, bestArea := n.Children[0], math.Inf(1), math.Inf(1)
+	for _, child := range n.Children {
+		area := child.Box.area()
+		growth := child.Box.Union(entry.Box).area() - area
+		if growth < bestGrowth || growth == bestGrowth && area < bestArea {
+			best, bestGrowth, bestArea = child, growth, area
+		}
+	}
+
+	if sibling := best.insert(entry); sibling != nil {
+		n.Children = append(n.Children, sibling)
+		if len(n.Children) > RTreeNodeCapacity {
+			return n.splitChildren()
+		}
+	}
+	return nil
+}
+
+func (n *rtreeNode) splitEntries() *rtreeNode {
+	sortAlongWidestAxis(n.Entries, n.Box, func(e *rtreeEntry) BoundingBox { return e.Box })
+	middle := len(n.Entries) / 2
+	sibling := &rtreeNode{Entries: slices.Clone(n.Entries[middle:])}
+	n.Entries = n.Entries[:middle]
+	n.recompute()
+	sibling.recompute()
+	return sibling
+}
+
+func (n *rtreeNode) splitChildren() *rtreeNode {
+	sortAlongWidestAxis(n.Children, n.Box, func(c *rtreeNode) BoundingBox { return c.Box })
+	middle := len(n.Children) / 2
+	sibling := &rtreeNode{Children: slices.Clone(n.Children[middle:])}
+	n.Children = n.Children[:middle]
+	n.recompute()
+	sibling.recompute()
+	return sibling
+}
+
+func sortAlongWidestAxis[T any](items []T, box BoundingBox, itemBox func(T) BoundingBox) {
+	if box.MaxLongitude-box.MinLongitude >= box.MaxLatitude-box.MinLatitude {
+		sortBy(items, func(item T) float64 {
+			x, _ := itemBox(item).center()
+			return x
+		})
+	} else {
+		sortBy(items, func(item T) float64 {
+			_, y := itemBox(item).center()
+			return y
+		})
+	}
+}
+
+func (n *rtreeNode) recompute() {
+	n.Box = BoundingBox{}
+	if n.isLeaf() {
+		for i, entry := range n.Entries {
+			if i == 0 {
+				n.Box = entry.Box
+			} else {
+				n.Box = n.Box.Union(entry.Box)
+			}
+		}
+		return
+	}
+	for i, child := range n.Children {
+		if i == 0 {
+			n.Box = child.Box
+		} else {
+			n.Box = n.Box.Union(child.Box)
+		}
+	}
+}
+
+func (t *RTree) Remove(edge *Edge) {
+	entries := segmentEntries([]*Edge{edge})
+	if t.Size == 0 || len(entries) == 0 {
+		return
+	}
+
+	box := entries[0].Box
+	for _, entry := range entries[1:] {
+		box = box.Union(entry.Box)
+	}
+	if t.Root.remove(edge, box) > 0 {
+		t.Size--
+	}
+
+	for !t.Root.isLeaf() && len(t.Root.Children) == 1 {
+		t.Root = t.Root.Children[0]
+	}
+	if t.Root.empty() {
+		t.Root = &rtreeNode{}
+	}
+}
+
+func (n *rtreeNode) empty() bool {
+	return len(n.Entries) == 0 && len(n.Children) == 0
+}
+
+func (n *rtreeNode) remove(edge *Edge, box BoundingBox) (removed int) {
+	if !n.Box.Intersects(box) {
+		return 0
+	}
+
+	if n.isLeaf() {
+		n.Entries = slices.DeleteFunc(n.Entries, func(entry *rtreeEntry) bool {
+			if entry.Edge == edge {
+				removed++
+				return true
+			}
+			return false
+		})
+	} else {
+		for _, child := range n.Children {
+			removed += child.remove(edge, box)
+		}
+		n.Children = slices.DeleteFunc(n.Children, (*rtreeNode).empty)
+	}
+
+	if removed > 0 {
+		n.recompute()
+	}
+	return
+}
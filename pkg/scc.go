@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"cmp"
+	"slices"
+)
+
+type ComponentStats struct {
+	Components int         `json:"components"`
+	Largest    int         `json:"largest"`
+	Singletons int         `json:"singletons"`
+	Sizes      map[int]int `json:"sizes"`
+}
+
+type tarjanFrame struct {
+	node       *Node
+	neighbours []*Node
+	next       int
+}
+
+func newTarjanFrame(node *Node) *tarjanFrame {
+	frame := &tarjanFrame{node: node, neighbours: make([]*Node, 0, len(node.OutEdges))}
+	for neighbour := range node.OutEdges {
+		frame.neighbours = append(frame.neighbours, neighbour)
+	}
+	return frame
+}
+
+func (g *Graph) StronglyConnectedComponents() (components [][]*Node) {
+	index, low, onStack := make(map[*Node]int), make(map[*Node]int), make(map[*Node]bool)
+	stack := make([]*Node, 0)
+	visit := func(node *Node) *tarjanFrame {
+		index[node], low[node] = len(index), len(index)
+		stack, onStack[node] = append(stack, node), true
+		return newTarjanFrame(node)
+	}
+
+	for _, root := range g.sortedNodes() {
+		if _, ok := index[root]; ok {
+			continue
+		}
+
+		calls := []*tarjanFrame{visit(root)}
+		for len(calls) > 0 {
+			top := calls[len(calls)-1]
+			if top.next < len(top.neighbours) {
+				neighbour := top.neighbours[top.next]
+				top.next++
+				if _, ok := index[neighbour]; !ok {
+					calls = append(calls, visit(neighbour))
+				} else if onStack[neighbour] {
+					low[top.node] = min(low[top.node], index[neighbour])
+				}
+				continue
+			}
+
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				parent := calls[len(calls)-1].node
+				low[parent] = min(low[parent], low[top.node])
+			}
+			if low[top.node] != index[top.node] {
+				continue
+			}
+
+			component := make([]*Node, 0)
+			for {
+				node := stack[len(stack)-1]
+				stack, onStack[node] = stack[:len(stack)-1], false
+				component = append(component, node)
+				if node == top.node {
+					break
+				}
+			}
+			slices.SortFunc(component, func(a, b *Node) int { return cmp.Compare(a.ID, b.ID) })
+			components = append(components, component)
+		}
+	}
+
+	slices.SortFunc(components, func(a, b []*Node) int {
+		if len(a) != len(b) {
+			return cmp.Compare(len(b), len(a))
+		}
+		return cmp.Compare(a[0].ID, b[0].ID)
+	})
+	return
+}
+
+func NewComponentStats(components [][]*Node) ComponentStats {
+	stats := ComponentStats{Components: len(components), Sizes: make(map[int]int)}
+	for _, component := range components {
+		stats.Largest = max(stats.Largest, len(component))
+		stats.Sizes[len(component)]++
+		if len(component) == 1 {
+			stats.Singletons++
+		}
+	}
+	return stats
+}
+
+func smallComponentNodes(components [][]*Node, minSize int) map[*Node]bool {
+	small := make(map[*Node]bool)
+	for _, component := range components {
+		if len(component) < minSize {
+			for _, node := range component {
+				small[node] = true
+			}
+		}
+	}
+	return small
+}
+
+func (g *Graph) RemoveNode(id string) error {
+	node, ok := g.Nodes[id]
+	if !ok {
+		return ErrNodeNotFound
+	}
+
+	for _, adjacent := range []map[*Node][]*Edge{node.OutEdges, node.InEdges} {
+		for _, edges := range adjacent {
+			for _, edge := range slices.Clone(edges) {
+				if err := g.RemoveEdge(edge.ID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	delete(g.Nodes, id)
+	return nil
+}
+
+func (g *Graph) DropSmallComponents(minSize int) (removed []*Node, err error) {
+	small := smallComponentNodes(g.StronglyConnectedComponents(), minSize)
+	for _, node := range g.sortedNodes() {
+		if !small[node] {
+			continue
+		}
+		if err := g.RemoveNode(node.ID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, node)
+	}
+	return
+}
+
+func (g *Graph) FlagSmallComponents(minSize int) (flagged []*Edge) {
+	small := smallComponentNodes(g.StronglyConnectedComponents(), minSize)
+	for _, edge := range g.sortedEdges() {
+		isolated := small[g.Nodes[edge.Start]] || small[g.Nodes[edge.End]]
+		if g.Index != nil && isolated && !edge.Isolated {
+			g.Index.Remove(edge)
+		} else if g.Index != nil && !isolated && edge.Isolated {
+			g.Index.Insert(edge)
+		}
+
+		if edge.Isolated = isolated; isolated {
+			flagged = append(flagged, edge)
+		}
+	}
+	return
+}
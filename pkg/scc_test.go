@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+// directedGraph adds one-way edges named after their single-letter ends, so
+// "ab" runs from a to b.
+func directedGraph(t *testing.T, positions map[string]Point, edges ...string) *Graph {
+	t.Helper()
+	g := NewGraph()
+	for id, position := range positions {
+		if _, err := g.AddNode(id, position); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range edges {
+		start, end := g.Nodes[id[:1]], g.Nodes[id[1:]]
+		if _, err := g.AddEdge(id, start, end, 10, []Point{start.Position, end.Position}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+func componentIDs(components [][]*Node) [][]string {
+	ids := make([][]string, len(components))
+	for i, component := range components {
+		for _, node := range component {
+			ids[i] = append(ids[i], node.ID)
+		}
+	}
+	return ids
+}
+
+var (
+	cyclePositions = map[string]Point{
+		"a": {Longitude: 0, Latitude: 0},
+		"b": {Longitude: 0.001, Latitude: 0},
+		"c": {Longitude: 0.001, Latitude: 0.001},
+	}
+	spurPositions = map[string]Point{
+		"a": {Longitude: 0, Latitude: 0},
+		"b": {Longitude: 0.001, Latitude: 0},
+		"c": {Longitude: 0.001, Latitude: 0.001},
+		"x": {Longitude: 0.003, Latitude: 0.001},
+	}
+)
+
+func TestComponentsOfAOneWayCycle(t *testing.T) {
+	g := directedGraph(t, cyclePositions, "ab", "bc", "ca")
+	components := componentIDs(g.StronglyConnectedComponents())
+	if len(components) != 1 || !slices.Equal(components[0], []string{"a", "b", "c"}) {
+		t.Errorf("components = %v, want [[a b c]]", components)
+	}
+
+	g = directedGraph(t, cyclePositions, "ab", "bc", "ac")
+	if components := componentIDs(g.StronglyConnectedComponents()); len(components) != 3 {
+		t.Errorf("reversing one edge of the cycle left components %v, want three singletons", components)
+	}
+}
+
+func TestComponentsSplitOffADeadEndSpur(t *testing.T) {
+	g := directedGraph(t, spurPositions, "ab", "bc", "ca", "cx")
+	components := g.StronglyConnectedComponents()
+	if ids := componentIDs(components); len(ids) != 2 || !slices.Equal(ids[0], []string{"a", "b", "c"}) || !slices.Equal(ids[1], []string{"x"}) {
+		t.Fatalf("components = %v, want [[a b c] [x]]", ids)
+	}
+	stats := NewComponentStats(components)
+	if stats.Components != 2 || stats.Largest != 3 || stats.Singletons != 1 || !maps.Equal(stats.Sizes, map[int]int{1: 1, 3: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	removed, err := g.DropSmallComponents(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].ID != "x" {
+		t.Errorf("removed %v, want [x]", removed)
+	}
+	if g.Edges["cx"] != nil || len(g.Nodes["c"].OutEdges) != 1 {
+		t.Error("the spur edge survived its node")
+	}
+}
+
+func TestFlagSmallComponentsFollowsTheIndex(t *testing.T) {
+	for _, build := range []func([]*Edge) SpatialIndex{
+		func(edges []*Edge) SpatialIndex { return NewRTree(edges) },
+		func(edges []*Edge) SpatialIndex {
+			index := segmentIndex(edges...)
+			for _, edge := range edges {
+				index.Padding = max(index.Padding, edge.Length/2)
+			}
+			return index
+		},
+	} {
+		g := directedGraph(t, spurPositions, "ab", "bc", "ca", "cx")
+		g.Index = build(g.sortedEdges())
+		spur := Point{Longitude: 0.002, Latitude: 0.001}
+		indexed := func() bool {
+			return slices.Contains(candidateIDs(g.Index.WithinRadius(spur, 10)), "cx")
+		}
+
+		flagged := g.FlagSmallComponents(2)
+		if len(flagged) != 1 || flagged[0].ID != "cx" || !g.Edges["cx"].Isolated {
+			t.Fatalf("%T: flagged %d edges, want the spur cx", g.Index, len(flagged))
+		}
+		if indexed() {
+			t.Errorf("%T: the flagged spur is still indexed", g.Index)
+		}
+		if g.Edges["ab"].Isolated || len(g.Index.WithinRadius(Point{Longitude: 0.0005}, 10)) != 1 {
+			t.Errorf("%T: an edge of the large component was flagged", g.Index)
+		}
+
+		x, c := g.Nodes["x"], g.Nodes["c"]
+		if _, err := g.AddEdge("xc", x, c, 10, []Point{x.Position, c.Position}); err != nil {
+			t.Fatal(err)
+		}
+		if flagged := g.FlagSmallComponents(2); len(flagged) != 0 || g.Edges["cx"].Isolated {
+			t.Errorf("%T: still flagged %d edges after closing the spur", g.Index, len(flagged))
+		}
+		if !indexed() {
+			t.Errorf("%T: the spur was not indexed again", g.Index)
+		}
+	}
+}
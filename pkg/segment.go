@@ -2,10 +2,16 @@ package pkg
 
 import (
 	"cmp"
+	"math"
 	"slices"
 	"sort"
 )
 
+const (
+	NearestInitialRadius = 50.0
+	SegmentCellDegrees   = 0.01
+)
+
 type segment struct {
 	Start  float64
 	End    float64
@@ -100,11 +106,16 @@ func newSegment(nodes []*SegmentNode) *segment {
 }
 
 type Segment2D struct {
-	Start float64
-	End   float64
-	Seg   *segment
-	Left  *Segment2D
-	Right *Segment2D
+	Start   float64
+	End     float64
+	Padding float64
+	Seg     *segment
+	Left    *Segment2D
+	Right   *Segment2D
+	Size    int
+	cells   map[[2]int][]*Edge
+	added   map[*Edge][][2]int
+	removed map[*Edge]bool
 }
 
 func (s *Segment2D) GetInterval(l1, r1, l2, r2 float64) []*Edge {
@@ -158,10 +169,160 @@ func NewSegment2D(nodes []*SegmentNode) *Segment2D {
 			values = append(values, node.Point.Longitude)
 		}
 	}
-	return build2D(sortedNodes, values)
+	if len(values) == 0 {
+		return &Segment2D{Start: math.Inf(1), End: math.Inf(-1), Seg: &segment{}}
+	}
+	s := build2D(sortedNodes, values)
+	s.Size = len(uniqueEdges(sortedNodes))
+	return s
+}
+
+func (s *Segment2D) Get(point Point, distance float64) (edges []*Edge) {
+	for _, box := range point.BoundingBoxes(distance) {
+		edges = append(edges, s.GetInterval(box.MinLongitude, box.MaxLongitude, box.MinLatitude, box.MaxLatitude)...)
+	}
+	return
+}
+
+func cellOf(degrees float64) int {
+	return int(math.Floor(degrees / SegmentCellDegrees))
+}
+
+func (s *Segment2D) insertedIn(box BoundingBox) (edges []*Edge) {
+	minRow, maxRow := cellOf(box.MinLatitude), cellOf(box.MaxLatitude)
+	minColumn, maxColumn := cellOf(box.MinLongitude), cellOf(box.MaxLongitude)
+	if (maxRow-minRow+1)*(maxColumn-minColumn+1) > len(s.cells) {
+		for key, cell := range s.cells {
+			if minRow <= key[0] && key[0] <= maxRow && minColumn <= key[1] && key[1] <= maxColumn {
+				edges = append(edges, cell...)
+			}
+		}
+		return
+	}
+	for row := minRow; row <= maxRow; row++ {
+		for column := minColumn; column <= maxColumn; column++ {
+			edges = append(edges, s.cells[[2]int{row, column}]...)
+		}
+	}
+	return
+}
+
+func (s *Segment2D) inserted(point Point, meters float64) (edges []*Edge) {
+	for _, box := range point.BoundingBoxes(meters) {
+		edges = append(edges, s.insertedIn(box)...)
+	}
+	return
+}
+
+// Intersecting pads box by the padding, since an edge crossing it may have
+// its nearest stored point up to that far outside.
+func (s *Segment2D) Intersecting(box BoundingBox) []*Edge {
+	candidates := s.insertedIn(box)
+	for _, padded := range box.Pad(s.Padding) {
+		candidates = append(candidates, s.GetInterval(padded.MinLongitude, padded.MaxLongitude, padded.MinLatitude, padded.MaxLatitude)...)
+	}
+
+	edges, seen := make([]*Edge, 0), make(map[*Edge]bool)
+	for _, edge := range candidates {
+		if !seen[edge] && !s.removed[edge] {
+			seen[edge] = true
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+func (s *Segment2D) WithinRadius(point Point, meters float64) []Candidate {
+	candidates, seen := make([]Candidate, 0), make(map[*Edge]bool)
+	for _, edge := range slices.Concat(s.Get(point, meters+s.Padding), s.inserted(point, meters)) {
+		if seen[edge] || s.removed[edge] {
+			continue
+		}
+		seen[edge] = true
+
+		closest := point.ClosestPointOnEdge(edge)
+		if distance := point.Distance(closest); distance <= meters {
+			candidates = append(candidates, Candidate{
+				Edge:     edge,
+				Point:    closest,
+				Distance: distance,
+			})
+		}
+	}
+	sortCandidates(candidates)
+	return candidates
+}
+
+func (s *Segment2D) Nearest(point Point, k int) []Candidate {
+	if k <= 0 {
+		return []Candidate{}
+	}
+
+	for meters := NearestInitialRadius; ; meters *= 2 {
+		candidates := s.WithinRadius(point, meters)
+		if len(candidates) >= k {
+			return candidates[:k]
+		}
+		if len(candidates) >= s.Size || meters > math.Pi*EarthRadius {
+			return candidates
+		}
+	}
+}
+
+func (s *Segment2D) indexed(edge *Edge) bool {
+	return len(edge.Poly) > 0 && slices.Contains(s.Get(edge.Poly[0], 0), edge)
+}
+
+func (s *Segment2D) Insert(edge *Edge) {
+	if s.removed[edge] {
+		delete(s.removed, edge)
+		s.Size++
+		return
+	}
+	if _, ok := s.added[edge]; ok || s.indexed(edge) {
+		return
+	}
+	if s.cells == nil {
+		s.cells, s.added = make(map[[2]int][]*Edge), make(map[*Edge][][2]int)
+	}
+
+	keys, seen := make([][2]int, 0), make(map[[2]int]bool)
+	for i := 1; i < len(edge.Poly); i++ {
+		for _, box := range NewArcBoundingBoxes(edge.Poly[i-1], edge.Poly[i]) {
+			for row := cellOf(box.MinLatitude); row <= cellOf(box.MaxLatitude); row++ {
+				for column := cellOf(box.MinLongitude); column <= cellOf(box.MaxLongitude); column++ {
+					if key := [2]int{row, column}; !seen[key] {
+						seen[key] = true
+						s.cells[key] = append(s.cells[key], edge)
+						keys = append(keys, key)
+					}
+				}
+			}
+		}
+	}
+	s.added[edge] = keys
+	s.Size++
 }
 
-func (s *Segment2D) Get(point Point, distance float64) []*Edge {
-	buttomLeft, topRight := point.Move(-distance, -distance), point.Move(distance, distance)
-	return s.GetInterval(buttomLeft.Longitude, topRight.Longitude, buttomLeft.Latitude, topRight.Latitude)
+func (s *Segment2D) Remove(edge *Edge) {
+	if keys, ok := s.added[edge]; ok {
+		for _, key := range keys {
+			if cell := slices.DeleteFunc(s.cells[key], func(e *Edge) bool { return e == edge }); len(cell) == 0 {
+				delete(s.cells, key)
+			} else {
+				s.cells[key] = cell
+			}
+		}
+		delete(s.added, edge)
+		s.Size--
+		return
+	}
+	if s.removed[edge] || !s.indexed(edge) {
+		return
+	}
+	if s.removed == nil {
+		s.removed = make(map[*Edge]bool)
+	}
+	s.removed[edge] = true
+	s.Size--
 }
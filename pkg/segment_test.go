@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"slices"
+	"testing"
+)
+
+func segmentEdge(id string, poly ...Point) *Edge {
+	return &Edge{ID: id, Poly: poly}
+}
+
+func segmentIndex(edges ...*Edge) *Segment2D {
+	nodes := make([]*SegmentNode, 0)
+	for _, edge := range edges {
+		for _, point := range edge.Poly {
+			nodes = append(nodes, &SegmentNode{Point: point, Edge: edge})
+		}
+	}
+	return NewSegment2D(nodes)
+}
+
+func candidateIDs(candidates []Candidate) []string {
+	ids := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.Edge.ID
+	}
+	return ids
+}
+
+func TestSegment2DNearestCountsDistinctEdges(t *testing.T) {
+	curvy := segmentEdge("curvy", Point{0, 0}, Point{0.0001, 0.0001}, Point{0.0002, 0}, Point{0.0003, 0.0001}, Point{0.0004, 0})
+	straight := segmentEdge("straight", Point{0, 0.001}, Point{0.0004, 0.001})
+	s := segmentIndex(curvy, straight)
+	if s.Size != 2 {
+		t.Fatalf("size %d, want 2 distinct edges", s.Size)
+	}
+	if got := candidateIDs(s.Nearest(Point{0.0002, 0.0002}, 5)); !slices.Equal(got, []string{"curvy", "straight"}) {
+		t.Errorf("nearest %v, want [curvy straight]", got)
+	}
+}
+
+func TestSegment2DInsertedEdgesAreQueriedByCell(t *testing.T) {
+	near := segmentEdge("near", Point{0, 0}, Point{0.001, 0})
+	s := segmentIndex(near)
+	s.Padding = 100
+
+	far := segmentEdge("far", Point{10, 10}, Point{10.05, 10})
+	s.Insert(far)
+	s.Insert(far)
+	if s.Size != 2 {
+		t.Errorf("size after inserting twice %d, want 2", s.Size)
+	}
+	if got := candidateIDs(s.WithinRadius(Point{10.025, 10.0001}, 50)); !slices.Equal(got, []string{"far"}) {
+		t.Errorf("within radius of the middle of far %v, want [far]", got)
+	}
+	if got := candidateIDs(s.WithinRadius(Point{0.0005, 0}, 50)); !slices.Equal(got, []string{"near"}) {
+		t.Errorf("within radius of near %v, want [near]", got)
+	}
+	if got := candidateIDs(s.Nearest(Point{0.0005, 0}, 3)); !slices.Equal(got, []string{"near", "far"}) {
+		t.Errorf("nearest %v, want [near far]", got)
+	}
+
+	s.Remove(far)
+	s.Remove(near)
+	s.Remove(near)
+	if s.Size != 0 {
+		t.Errorf("size after removing both %d, want 0", s.Size)
+	}
+	if got := s.Nearest(Point{0.0005, 0}, 3); len(got) != 0 {
+		t.Errorf("nearest after removing both %v, want none", candidateIDs(got))
+	}
+	if len(s.cells) != 0 {
+		t.Errorf("%d cells left after removing the inserted edge", len(s.cells))
+	}
+
+	s.Insert(near)
+	if got := candidateIDs(s.Nearest(Point{0.0005, 0}, 3)); !slices.Equal(got, []string{"near"}) || s.Size != 1 {
+		t.Errorf("nearest after reinserting %v with size %d, want [near] and 1", got, s.Size)
+	}
+}
@@ -0,0 +1,349 @@
+package pkg
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+type SimplifyOptions struct {
+	SnapTolerance float64
+	MergeChains   bool
+}
+
+type SimplifyMapping struct {
+	Nodes    map[string]string `json:"nodes"`
+	Absorbed map[string]string `json:"absorbed"`
+	Edges    map[string]string `json:"edges"`
+	Dropped  []string          `json:"dropped"`
+}
+
+type snapGrid struct {
+	cell  float64
+	cells map[[2]int][]*Node
+}
+
+func (s *snapGrid) width(row int) float64 {
+	return s.longitudeSpan(math.Max(math.Abs(float64(row)*s.cell), math.Abs(float64(row+1)*s.cell)))
+}
+
+func (s *snapGrid) longitudeSpan(latitude float64) float64 {
+	if c := math.Cos(toRadians(math.Min(latitude, 90))); c > s.cell/360 {
+		return math.Min(360, s.cell/c)
+	}
+	return 360
+}
+
+func (s *snapGrid) column(row int, longitude float64) (int, int) {
+	width := s.width(row)
+	count := int(math.Ceil(360 / width))
+	column := int(math.Floor((NormalizeLongitude(longitude) + 180) / width))
+	return ((column % count) + count) % count, count
+}
+
+func (s *snapGrid) add(node *Node) {
+	row := int(math.Floor(node.Position.Latitude / s.cell))
+	column, _ := s.column(row, node.Position.Longitude)
+	s.cells[[2]int{row, column}] = append(s.cells[[2]int{row, column}], node)
+}
+
+func (s *snapGrid) near(point Point, visit func(*Node)) {
+	row := int(math.Floor(point.Latitude / s.cell))
+	for i := row - 1; i <= row+1; i++ {
+		width := s.width(i)
+		span := math.Min(180, s.longitudeSpan(math.Max(math.Abs(point.Latitude), math.Abs(float64(i)*s.cell))))
+		first, count := s.column(i, point.Longitude-span)
+		steps := int(math.Ceil(2*span/width)) + 1
+		for j := 0; j <= min(steps, count-1); j++ {
+			for _, node := range s.cells[[2]int{i, (first + j) % count}] {
+				visit(node)
+			}
+		}
+	}
+}
+
+// snapClusters maps every node to a representative within tolerance of it.
+// Nodes are visited in ID order and each joins the closest representative in
+// range or becomes one itself, so clusters cannot chain beyond the tolerance.
+func (g *Graph) snapClusters(tolerance float64) map[*Node]*Node {
+	nodes := g.sortedNodes()
+	clusters := make(map[*Node]*Node, len(nodes))
+	if tolerance <= 0 {
+		for _, node := range nodes {
+			clusters[node] = node
+		}
+		return clusters
+	}
+
+	grid := &snapGrid{cell: toDegrees(tolerance / EarthRadius), cells: make(map[[2]int][]*Node)}
+	for _, node := range nodes {
+		var best *Node
+		bestDistance := tolerance
+		grid.near(node.Position, func(representative *Node) {
+			distance := node.Position.Distance(representative.Position)
+			if distance < bestDistance || distance == bestDistance && (best == nil || representative.ID < best.ID) {
+				best, bestDistance = representative, distance
+			}
+		})
+		if best == nil {
+			best = node
+			grid.add(node)
+		}
+		clusters[node] = best
+	}
+	return clusters
+}
+
+func (g *Graph) snapped(tolerance float64, mapping *SimplifyMapping) (*Graph, error) {
+	clusters := g.snapClusters(tolerance)
+	result := NewGraph()
+	for _, node := range g.sortedNodes() {
+		root := clusters[node]
+		mapping.Nodes[node.ID] = root.ID
+		if node == root {
+			if _, err := result.AddNode(root.ID, root.Position); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, edge := range g.sortedEdges() {
+		start, end := clusters[g.Nodes[edge.Start]], clusters[g.Nodes[edge.End]]
+		if start == end && edge.Length <= tolerance {
+			mapping.Dropped = append(mapping.Dropped, edge.ID)
+			continue
+		}
+
+		poly := slices.Clone(edge.Poly)
+		if len(poly) > 0 {
+			poly[0], poly[len(poly)-1] = start.Position, end.Position
+		}
+		copied, err := result.AddEdge(edge.ID, result.Nodes[start.ID], result.Nodes[end.ID], edge.Speed, poly)
+		if err != nil {
+			return nil, err
+		}
+		copied.OSMWayID, copied.OSMNodeIDs, copied.Isolated, copied.Attributes = edge.OSMWayID, edge.OSMNodeIDs, edge.Isolated, edge.Attributes
+		copied.Profile = edge.Profile
+		mapping.Edges[edge.ID] = edge.ID
+	}
+	return result, nil
+}
+
+func sameAttributes(a, b *Edge) bool {
+	return a.Speed == b.Speed && a.Isolated == b.Isolated && a.Attributes.Equal(b.Attributes) && a.Profile.Equal(b.Profile)
+}
+
+func single(adjacent map[*Node][]*Edge) []*Edge {
+	edges := make([]*Edge, 0, len(adjacent))
+	for _, parallel := range adjacent {
+		if len(parallel) != 1 {
+			return nil
+		}
+		edges = append(edges, parallel[0])
+	}
+	return edges
+}
+
+func (g *Graph) passThrough(node *Node) map[*Edge]*Edge {
+	in, out := single(node.InEdges), single(node.OutEdges)
+	if in == nil || out == nil || len(in) != len(out) || len(in) == 0 || len(in) > 2 {
+		return nil
+	}
+	neighbours := make(map[string]bool)
+	for _, edge := range in {
+		neighbours[edge.Start] = true
+	}
+	for _, edge := range out {
+		neighbours[edge.End] = true
+	}
+	if len(neighbours) != 2 || neighbours[node.ID] {
+		return nil
+	}
+
+	next := make(map[*Edge]*Edge, len(in))
+	for _, incoming := range in {
+		for _, outgoing := range out {
+			if outgoing.End != incoming.Start {
+				if !sameAttributes(incoming, outgoing) {
+					return nil
+				}
+				next[incoming] = outgoing
+			}
+		}
+	}
+	if len(next) != len(in) {
+		return nil
+	}
+	return next
+}
+
+func (g *Graph) chains() [][]*Edge {
+	next := make(map[*Edge]*Edge)
+	for _, node := range g.Nodes {
+		for incoming, outgoing := range g.passThrough(node) {
+			next[incoming] = outgoing
+		}
+	}
+	continued := make(map[*Edge]bool, len(next))
+	for _, outgoing := range next {
+		continued[outgoing] = true
+	}
+
+	chains, covered := make([][]*Edge, 0), make(map[*Edge]bool)
+	for _, edge := range g.sortedEdges() {
+		if continued[edge] {
+			continue
+		}
+		chain := []*Edge{edge}
+		for current := edge; next[current] != nil; current = next[current] {
+			chain = append(chain, next[current])
+		}
+		for _, edge := range chain {
+			covered[edge] = true
+		}
+		chains = append(chains, chain)
+	}
+
+	for _, edge := range g.sortedEdges() {
+		if !covered[edge] {
+			chains = append(chains, []*Edge{edge})
+		}
+	}
+	return chains
+}
+
+func isTwinChain(chain, other []*Edge) bool {
+	if len(chain) != len(other) {
+		return false
+	}
+	for i, edge := range chain {
+		if !edge.IsReverseOf(other[len(other)-1-i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Graph) twinChain(chain []*Edge, byFirst map[*Edge][]*Edge) []*Edge {
+	last := chain[len(chain)-1]
+	id, ok := strings.CutSuffix(last.ID, ReverseSuffix)
+	if !ok {
+		id = last.ID + ReverseSuffix
+	}
+	if edge, ok := g.Edges[id]; ok && isTwinChain(chain, byFirst[edge]) {
+		return byFirst[edge]
+	}
+	return nil
+}
+
+func (g *Graph) chainNames(chains [][]*Edge) map[*Edge]string {
+	byFirst := make(map[*Edge][]*Edge, len(chains))
+	for _, chain := range chains {
+		byFirst[chain[0]] = chain
+	}
+
+	names := make(map[*Edge]string, len(chains))
+	for _, chain := range chains {
+		if _, ok := names[chain[0]]; ok {
+			continue
+		}
+		twin := g.twinChain(chain, byFirst)
+		if twin == nil {
+			names[chain[0]] = chain[0].ID
+			continue
+		}
+
+		base := min(strings.TrimSuffix(chain[0].ID, ReverseSuffix), strings.TrimSuffix(twin[0].ID, ReverseSuffix))
+		if !slices.ContainsFunc(chain, func(e *Edge) bool { return e.ID == base }) {
+			chain, twin = twin, chain
+		}
+		names[chain[0]], names[twin[0]] = base, base+ReverseSuffix
+	}
+	return names
+}
+
+func mergeChain(graph *Graph, id string, chain []*Edge) error {
+	poly, nodeIDs, wayID := make([]Point, 0), []int64(nil), chain[0].OSMWayID
+	for _, edge := range chain {
+		for _, point := range edge.Poly {
+			if len(poly) == 0 || poly[len(poly)-1] != point {
+				poly = append(poly, point)
+			}
+		}
+		for _, nodeID := range edge.OSMNodeIDs {
+			if len(nodeIDs) == 0 || nodeIDs[len(nodeIDs)-1] != nodeID {
+				nodeIDs = append(nodeIDs, nodeID)
+			}
+		}
+		if edge.OSMWayID != wayID {
+			wayID = 0
+		}
+	}
+
+	edge, err := graph.AddEdge(id, graph.Nodes[chain[0].Start], graph.Nodes[chain[len(chain)-1].End], chain[0].Speed, poly)
+	if err != nil {
+		return err
+	}
+	edge.OSMWayID, edge.OSMNodeIDs, edge.Isolated, edge.Attributes = wayID, nodeIDs, chain[0].Isolated, chain[0].Attributes
+	edge.Profile = chain[0].Profile
+	return nil
+}
+
+func (g *Graph) mergedChains(mapping *SimplifyMapping) (*Graph, error) {
+	chains := g.chains()
+	names, absorbed := g.chainNames(chains), make(map[string]string)
+	for _, chain := range chains {
+		for _, edge := range chain[1:] {
+			if _, ok := absorbed[edge.Start]; !ok {
+				absorbed[edge.Start] = names[chain[0]]
+			}
+		}
+	}
+
+	result := NewGraph()
+	for _, node := range g.sortedNodes() {
+		if _, ok := absorbed[node.ID]; !ok {
+			if _, err := result.AddNode(node.ID, node.Position); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, chain := range chains {
+		id := names[chain[0]]
+		if err := mergeChain(result, id, chain); err != nil {
+			return nil, err
+		}
+		for _, edge := range chain {
+			mapping.Edges[edge.ID] = id
+		}
+	}
+
+	for old, current := range mapping.Nodes {
+		if id, ok := absorbed[current]; ok {
+			mapping.Absorbed[old] = id
+			delete(mapping.Nodes, old)
+		}
+	}
+	return result, nil
+}
+
+func Simplify(graph *Graph, options SimplifyOptions) (*Graph, *SimplifyMapping, error) {
+	mapping := &SimplifyMapping{
+		Nodes:    make(map[string]string),
+		Absorbed: make(map[string]string),
+		Edges:    make(map[string]string),
+		Dropped:  make([]string, 0),
+	}
+
+	result, err := graph.snapped(options.SnapTolerance, mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+	if options.MergeChains {
+		if result, err = result.mergedChains(mapping); err != nil {
+			return nil, nil, err
+		}
+	}
+	return result, mapping, nil
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// lineGraph places one node per ID along the equator, spacing metres apart,
+// and joins consecutive nodes with edges named after both ends, in both
+// directions when bidirectional is set.
+func lineGraph(t *testing.T, spacing float64, bidirectional bool, ids ...string) *Graph {
+	t.Helper()
+	g := NewGraph()
+	step := toDegrees(spacing / EarthRadius)
+	for i, id := range ids {
+		if _, err := g.AddNode(id, Point{Longitude: float64(i) * step}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i < len(ids); i++ {
+		start, end := g.Nodes[ids[i-1]], g.Nodes[ids[i]]
+		if _, err := g.AddEdge(start.ID+end.ID, start, end, 10, []Point{start.Position, end.Position}); err != nil {
+			t.Fatal(err)
+		}
+		if bidirectional {
+			if _, err := g.AddEdge(start.ID+end.ID+ReverseSuffix, end, start, 10, []Point{end.Position, start.Position}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return g
+}
+
+func edgeIDs(g *Graph) []string {
+	ids := make([]string, 0, len(g.Edges))
+	for _, edge := range g.sortedEdges() {
+		ids = append(ids, edge.ID)
+	}
+	return ids
+}
+
+func TestSnapDoesNotChainBeyondTheTolerance(t *testing.T) {
+	g := lineGraph(t, 6, false, "a", "b", "c", "d", "e")
+	simplified, mapping, err := Simplify(g, SimplifyOptions{SnapTolerance: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"a": "a", "b": "a", "c": "c", "d": "c", "e": "e"}
+	if !maps.Equal(mapping.Nodes, want) {
+		t.Fatalf("nodes = %v, want %v", mapping.Nodes, want)
+	}
+	for id, representative := range mapping.Nodes {
+		if d := g.Nodes[id].Position.Distance(g.Nodes[representative].Position); d > 10 {
+			t.Errorf("%s snapped %.1fm away to %s", id, d, representative)
+		}
+	}
+	if !slices.Equal(mapping.Dropped, []string{"ab", "cd"}) {
+		t.Errorf("dropped = %v, want [ab cd]", mapping.Dropped)
+	}
+
+	for id, ends := range map[string][2]string{"bc": {"a", "c"}, "de": {"c", "e"}} {
+		edge := simplified.Edges[id]
+		if edge == nil || edge.Start != ends[0] || edge.End != ends[1] {
+			t.Fatalf("%s = %+v, want %s→%s", id, edge, ends[0], ends[1])
+		}
+		if edge.Poly[0] != simplified.Nodes[ends[0]].Position || edge.Poly[len(edge.Poly)-1] != simplified.Nodes[ends[1]].Position {
+			t.Errorf("%s geometry does not end at its snapped nodes", id)
+		}
+	}
+	if len(simplified.Nodes) != 3 || len(simplified.Edges) != 2 {
+		t.Errorf("simplified to %d nodes and %d edges, want 3 and 2", len(simplified.Nodes), len(simplified.Edges))
+	}
+}
+
+func TestSnapJoinsTheClosestRepresentative(t *testing.T) {
+	g := lineGraph(t, 8, false, "a", "b", "c", "d")
+	g.Nodes["d"].Position = g.Nodes["c"].Position.MoveTowards(g.Nodes["b"].Position, 7)
+	_, mapping, err := Simplify(g, SimplifyOptions{SnapTolerance: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"a": "a", "b": "a", "c": "c", "d": "c"}; !maps.Equal(mapping.Nodes, want) {
+		t.Errorf("nodes = %v, want %v", mapping.Nodes, want)
+	}
+}
+
+func TestSimplifyMergesTwinChains(t *testing.T) {
+	g := lineGraph(t, 100, true, "a", "b", "c", "d")
+	simplified, mapping, err := Simplify(g, SimplifyOptions{MergeChains: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := edgeIDs(simplified); !slices.Equal(ids, []string{"ab", "ab" + ReverseSuffix}) {
+		t.Fatalf("edges = %v, want [ab ab%s]", ids, ReverseSuffix)
+	}
+	forward, backward := simplified.Edges["ab"], simplified.Edges["ab"+ReverseSuffix]
+	if forward.Start != "a" || forward.End != "d" || backward.Start != "d" || backward.End != "a" {
+		t.Errorf("merged edges run %s→%s and %s→%s, want a→d and d→a", forward.Start, forward.End, backward.Start, backward.End)
+	}
+	if len(forward.Poly) != 4 || math.Abs(forward.Length-300) > 0.01 {
+		t.Errorf("merged geometry has %d points and length %.1f", len(forward.Poly), forward.Length)
+	}
+
+	for id := range g.Edges {
+		want := "ab"
+		if strings.HasSuffix(id, ReverseSuffix) {
+			want += ReverseSuffix
+		}
+		if mapping.Edges[id] != want {
+			t.Errorf("%s mapped to %q, want %q", id, mapping.Edges[id], want)
+		}
+	}
+	if want := map[string]string{"b": "ab", "c": "ab"}; !maps.Equal(mapping.Absorbed, want) {
+		t.Errorf("absorbed = %v, want %v", mapping.Absorbed, want)
+	}
+	if want := map[string]string{"a": "a", "d": "d"}; !maps.Equal(mapping.Nodes, want) {
+		t.Errorf("nodes = %v, want %v", mapping.Nodes, want)
+	}
+}
+
+func TestSimplifyStopsChainsAtAttributeBoundaries(t *testing.T) {
+	for name, change := range map[string]func(*Edge){
+		"speed":   func(e *Edge) { e.Speed = 20 },
+		"class":   func(e *Edge) { e.Attributes = &Attributes{Class: "primary"} },
+		"profile": func(e *Edge) { e.Profile = &SpeedProfile{SlotMinutes: 60, Speeds: make([]float64, 168)} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			g := lineGraph(t, 100, false, "a", "b", "c", "d")
+			change(g.Edges["cd"])
+			simplified, mapping, err := Simplify(g, SimplifyOptions{MergeChains: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if ids := edgeIDs(simplified); !slices.Equal(ids, []string{"ab", "cd"}) {
+				t.Fatalf("edges = %v, want [ab cd]", ids)
+			}
+			if edge := simplified.Edges["ab"]; edge.Start != "a" || edge.End != "c" {
+				t.Errorf("ab runs %s→%s, want a→c", edge.Start, edge.End)
+			}
+			if want := map[string]string{"ab": "ab", "bc": "ab", "cd": "cd"}; !maps.Equal(mapping.Edges, want) {
+				t.Errorf("edges = %v, want %v", mapping.Edges, want)
+			}
+			if want := map[string]string{"b": "ab"}; !maps.Equal(mapping.Absorbed, want) {
+				t.Errorf("absorbed = %v, want %v", mapping.Absorbed, want)
+			}
+			if simplified.Nodes["c"] == nil {
+				t.Error("the boundary node c was absorbed")
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	MinutesPerWeek = 7 * 24 * 60
+)
+
+var (
+	ErrInvalidProfile = errors.New("invalid speed profile")
+)
+
+type SpeedProfile struct {
+	SlotMinutes int       `json:"slot_minutes"`
+	Speeds      []float64 `json:"speeds"`
+}
+
+func NewSpeedProfile(slotMinutes int, speeds []float64) (*SpeedProfile, error) {
+	if slotMinutes <= 0 || len(speeds) == 0 || MinutesPerWeek%(slotMinutes*len(speeds)) != 0 {
+		return nil, fmt.Errorf("%w: %d slots of %d minutes do not divide a week", ErrInvalidProfile, len(speeds), slotMinutes)
+	}
+	for i, speed := range speeds {
+		if math.IsNaN(speed) || math.IsInf(speed, 0) || speed < 0 {
+			return nil, fmt.Errorf("%w: slot %d has speed %v", ErrInvalidProfile, i, speed)
+		}
+	}
+	return &SpeedProfile{SlotMinutes: slotMinutes, Speeds: speeds}, nil
+}
+
+func (p *SpeedProfile) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		SlotMinutes int       `json:"slot_minutes"`
+		Speeds      []float64 `json:"speeds"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	profile, err := NewSpeedProfile(fields.SlotMinutes, fields.Speeds)
+	if err != nil {
+		return err
+	}
+	*p = *profile
+	return nil
+}
+
+func weekOffset(t time.Time) float64 {
+	day := (int(t.Weekday()) + 6) % 7
+	hour, minute, second := t.Clock()
+	return float64(((day*24+hour)*60+minute)*60+second) + float64(t.Nanosecond())/1e9
+}
+
+func (p *SpeedProfile) slot(offset float64) (int, float64) {
+	length := float64(p.SlotMinutes * 60)
+	return int(offset/length) % len(p.Speeds), length - math.Mod(offset, length)
+}
+
+func (p *SpeedProfile) Equal(other *SpeedProfile) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	return p.SlotMinutes == other.SlotMinutes && slices.Equal(p.Speeds, other.Speeds)
+}
+
+func (p *SpeedProfile) SpeedAt(t time.Time) float64 {
+	index, _ := p.slot(weekOffset(t))
+	return p.Speeds[index]
+}
+
+func (p *SpeedProfile) TravelTime(distance float64, departure time.Time) float64 {
+	offset, elapsed, stalled := weekOffset(departure), 0.0, 0
+	for distance > 0 {
+		index, remaining := p.slot(offset)
+		speed := p.Speeds[index]
+		if speed <= 0 {
+			if stalled++; stalled > len(p.Speeds) {
+				return math.Inf(1)
+			}
+			elapsed, offset = elapsed+remaining, offset+remaining
+			continue
+		}
+
+		stalled = 0
+		if speed*remaining >= distance {
+			return elapsed + distance/speed
+		}
+		distance -= speed * remaining
+		elapsed, offset = elapsed+remaining, offset+remaining
+	}
+	return elapsed
+}
+
+func (e *Edge) SpeedAt(t time.Time) float64 {
+	if e.Profile == nil {
+		return e.Speed
+	}
+	return e.Profile.SpeedAt(t)
+}
+
+func (e *Edge) TravelTime(departure time.Time) float64 {
+	if e.Profile != nil {
+		return e.Profile.TravelTime(e.Length, departure)
+	}
+	if e.Speed <= 0 {
+		return math.Inf(1)
+	}
+	return e.Length / e.Speed
+}
+
+func (g *Graph) FastestPath(start, end *Node, departure time.Time) ([]*Edge, time.Duration, error) {
+	arrivals, parents, visited := map[*Node]float64{start: 0}, make(map[*Node]*Edge), make(map[*Node]bool)
+	queue := NewHeap(func(i, j interface{}) bool {
+		if i.(heapNode).distance == j.(heapNode).distance {
+			return i.(heapNode).node.ID < j.(heapNode).node.ID
+		}
+		return i.(heapNode).distance < j.(heapNode).distance
+	})
+	queue.Push(heapNode{node: start, distance: 0})
+
+	for queue.Length() > 0 {
+		current := queue.Pop().(heapNode)
+		if visited[current.node] {
+			continue
+		}
+		visited[current.node] = true
+		if current.node == end {
+			break
+		}
+
+		at := departure.Add(time.Duration(current.distance * float64(time.Second)))
+		for neighbour, parallel := range current.node.OutEdges {
+			if visited[neighbour] {
+				continue
+			}
+			for _, edge := range parallel {
+				arrival := current.distance + g.TravelTime(edge, at)
+				if math.IsInf(arrival, 1) {
+					continue
+				}
+				if best, ok := arrivals[neighbour]; !ok || arrival < best {
+					arrivals[neighbour], parents[neighbour] = arrival, edge
+					queue.Push(heapNode{node: neighbour, distance: arrival})
+				}
+			}
+		}
+	}
+
+	if !visited[end] {
+		return nil, 0, ErrNodeNotReachable
+	}
+	path := make([]*Edge, 0)
+	for current := end; current != start; current = g.Nodes[parents[current].Start] {
+		path = append(path, parents[current])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, time.Duration(arrivals[end] * float64(time.Second)), nil
+}
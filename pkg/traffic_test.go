@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+// hourlyProfile repeats every day; speeds[h] applies from h:00 to h:59.
+func hourlyProfile(t *testing.T, speeds map[int]float64, otherwise float64) *SpeedProfile {
+	t.Helper()
+	hours := make([]float64, 24)
+	for hour := range hours {
+		hours[hour] = otherwise
+		if speed, ok := speeds[hour]; ok {
+			hours[hour] = speed
+		}
+	}
+	profile, err := NewSpeedProfile(60, hours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+// monday is the start of the profile week.
+func monday(hour, minute, second int) time.Time {
+	return time.Date(2024, 1, 1, hour, minute, second, 0, time.UTC)
+}
+
+func TestTravelTimeAcrossASlotBoundary(t *testing.T) {
+	profile := hourlyProfile(t, map[int]float64{8: 5, 9: 20}, 10)
+	// 30 s at 5 m/s covers 150 m; the other 850 m take 42.5 s at 20 m/s.
+	if got := profile.TravelTime(1000, monday(8, 59, 30)); math.Abs(got-72.5) > 1e-9 {
+		t.Errorf("travel time across 09:00 %vs, want 72.5s", got)
+	}
+	if got := profile.TravelTime(100, monday(8, 0, 0)); math.Abs(got-20) > 1e-9 {
+		t.Errorf("travel time within a slot %vs, want 20s", got)
+	}
+}
+
+func TestTravelTimeWaitsOutZeroSpeedSlots(t *testing.T) {
+	profile := hourlyProfile(t, map[int]float64{3: 0, 4: 0}, 10)
+	if got := profile.TravelTime(100, monday(3, 30, 0)); math.Abs(got-(90*60+10)) > 1e-9 {
+		t.Errorf("travel time from 03:30 %vs, want 90 minutes and 10s", got)
+	}
+
+	stopped := hourlyProfile(t, nil, 0)
+	if got := stopped.TravelTime(100, monday(12, 0, 0)); !math.IsInf(got, 1) {
+		t.Errorf("travel time on a stopped profile %vs, want +Inf", got)
+	}
+}
+
+func TestTravelTimeWrapsAroundTheWeek(t *testing.T) {
+	speeds := make([]float64, 7*24)
+	for i := range speeds {
+		speeds[i] = 15
+	}
+	speeds[0], speeds[len(speeds)-1] = 20, 10
+	profile, err := NewSpeedProfile(60, speeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sunday := time.Date(2024, 1, 7, 23, 59, 0, 0, time.UTC)
+	if got := profile.SpeedAt(sunday); got != 10 {
+		t.Errorf("speed on Sunday night %v, want 10", got)
+	}
+	// 60 s at 10 m/s on Sunday, then 1200 m at 20 m/s on Monday morning.
+	if got := profile.TravelTime(1800, sunday); math.Abs(got-120) > 1e-9 {
+		t.Errorf("travel time across the week boundary %vs, want 120s", got)
+	}
+}
+
+func TestTravelTimeIsFIFO(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		speeds := make([]float64, 48)
+		for j := range speeds {
+			speeds[j] = float64(random.Intn(4)) * 5
+		}
+		speeds[0] = 5 // so that no profile stalls forever
+		profile, err := NewSpeedProfile(30, speeds)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		distance, previous := random.Float64()*20000, math.Inf(-1)
+		for minute := 0; minute < 24*60; minute += 7 {
+			departure := monday(0, 0, 0).Add(time.Duration(minute) * time.Minute)
+			arrival := float64(minute*60) + profile.TravelTime(distance, departure)
+			if arrival < previous-1e-6 {
+				t.Fatalf("profile %v: leaving at minute %d arrives at %vs, before an earlier departure (%vs)", speeds, minute, arrival, previous)
+			}
+			previous = arrival
+		}
+	}
+}
+
+func TestFastestPathAvoidsACongestedSlot(t *testing.T) {
+	g := overrideGraph(t)
+	g.Edges["ab"].Profile = hourlyProfile(t, map[int]float64{8: 0}, 10)
+
+	var previous time.Time
+	for _, test := range []struct {
+		departure time.Time
+		want      []string
+	}{
+		{monday(7, 0, 0), []string{"ab", "bc"}},
+		{monday(7, 59, 55), []string{"ad", "dc"}},
+		{monday(8, 30, 0), []string{"ad", "dc"}},
+		{monday(9, 0, 0), []string{"ab", "bc"}},
+	} {
+		path, duration, err := g.FastestPath(g.Nodes["a"], g.Nodes["c"], test.departure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(path))
+		var want float64
+		for i, edge := range path {
+			ids[i] = edge.ID
+			want += edge.Length / 10
+		}
+		if !slices.Equal(ids, test.want) {
+			t.Errorf("leaving at %s: %v, want %v", test.departure.Format("15:04:05"), ids, test.want)
+		}
+		if math.Abs(duration.Seconds()-want) > 1e-3 {
+			t.Errorf("leaving at %s: %v, want %vs", test.departure.Format("15:04:05"), duration, want)
+		}
+		if arrival := test.departure.Add(duration); arrival.Before(previous) {
+			t.Errorf("leaving at %s arrives at %s, before an earlier departure", test.departure.Format("15:04:05"), arrival.Format("15:04:05"))
+		} else {
+			previous = arrival
+		}
+	}
+}
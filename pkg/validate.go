@@ -0,0 +1,158 @@
+package pkg
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	EndpointTolerance  = 1.0
+	ValidationExamples = 5
+)
+
+const (
+	IssueInvalidGeometry  = "invalid_geometry"
+	IssueZeroLength       = "zero_length_edge"
+	IssueEndpointMismatch = "endpoint_mismatch"
+	IssueReverseClash     = "reverse_suffix_clash"
+	IssueDuplicateID      = "duplicate_edge_id"
+	IssueIsolatedNode     = "isolated_node"
+	IssueSelfLoop         = "self_loop"
+	IssueInvalidSpeed     = "invalid_speed"
+	IssueParallelEdges    = "parallel_edges"
+)
+
+type Issue struct {
+	Kind     string   `json:"kind"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"`
+	Edges    []*Edge  `json:"-"`
+	Nodes    []*Node  `json:"-"`
+}
+
+type ValidationReport struct {
+	Nodes  int      `json:"nodes"`
+	Edges  int      `json:"edges"`
+	Issues []*Issue `json:"issues"`
+}
+
+func (r *ValidationReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *ValidationReport) Issue(kind string) *Issue {
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			return issue
+		}
+	}
+	return nil
+}
+
+func invalidPoint(point Point) bool {
+	return math.IsNaN(point.Longitude) || math.IsNaN(point.Latitude) ||
+		math.IsInf(point.Longitude, 0) || math.IsInf(point.Latitude, 0) ||
+		math.Abs(point.Latitude) > 90
+}
+
+func (g *Graph) sortedEdges() []*Edge {
+	edges := make([]*Edge, 0, len(g.Edges))
+	for _, edge := range g.Edges {
+		edges = append(edges, edge)
+	}
+	slices.SortFunc(edges, func(a, b *Edge) int { return cmp.Compare(a.ID, b.ID) })
+	return edges
+}
+
+func (g *Graph) sortedNodes() []*Node {
+	nodes := make([]*Node, 0, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes = append(nodes, node)
+	}
+	slices.SortFunc(nodes, func(a, b *Node) int { return cmp.Compare(a.ID, b.ID) })
+	return nodes
+}
+
+func (g *Graph) edgeIssues() map[string][]*Edge {
+	issues := make(map[string][]*Edge)
+	for _, edge := range g.sortedEdges() {
+		start, end := g.Nodes[edge.Start], g.Nodes[edge.End]
+
+		if len(edge.Poly) < 2 || slices.ContainsFunc(edge.Poly, invalidPoint) {
+			issues[IssueInvalidGeometry] = append(issues[IssueInvalidGeometry], edge)
+		} else if start != nil && end != nil && (edge.Poly[0].Distance(start.Position) > EndpointTolerance || edge.Poly[len(edge.Poly)-1].Distance(end.Position) > EndpointTolerance) {
+			issues[IssueEndpointMismatch] = append(issues[IssueEndpointMismatch], edge)
+		}
+
+		if !(edge.Length > 0) {
+			issues[IssueZeroLength] = append(issues[IssueZeroLength], edge)
+		}
+		if edge.Start == edge.End {
+			issues[IssueSelfLoop] = append(issues[IssueSelfLoop], edge)
+		}
+		if math.IsNaN(edge.Speed) || math.IsInf(edge.Speed, 0) || edge.Speed <= 0 {
+			issues[IssueInvalidSpeed] = append(issues[IssueInvalidSpeed], edge)
+		}
+
+		if base, ok := strings.CutSuffix(edge.ID, ReverseSuffix); ok {
+			if twin, ok := g.Edges[base]; !ok || !edge.IsReverseOf(twin) {
+				issues[IssueReverseClash] = append(issues[IssueReverseClash], edge)
+			}
+		}
+	}
+
+	for _, edge := range g.Rejected {
+		if len(edge.Poly) > 1 && !slices.ContainsFunc(edge.Poly, invalidPoint) && !(edge.Length > 0) {
+			issues[IssueZeroLength] = append(issues[IssueZeroLength], edge)
+		} else {
+			issues[IssueInvalidGeometry] = append(issues[IssueInvalidGeometry], edge)
+		}
+	}
+
+	for _, edge := range g.Clashes {
+		if strings.HasSuffix(edge.ID, ReverseSuffix) {
+			issues[IssueReverseClash] = append(issues[IssueReverseClash], edge)
+		} else {
+			issues[IssueDuplicateID] = append(issues[IssueDuplicateID], edge)
+		}
+	}
+
+	for _, group := range g.ParallelEdges() {
+		issues[IssueParallelEdges] = append(issues[IssueParallelEdges], group...)
+	}
+	return issues
+}
+
+func (g *Graph) Validate() *ValidationReport {
+	report := &ValidationReport{Nodes: len(g.Nodes), Edges: len(g.Edges), Issues: make([]*Issue, 0)}
+
+	edgeIssues := g.edgeIssues()
+	for _, kind := range []string{IssueInvalidGeometry, IssueZeroLength, IssueEndpointMismatch, IssueDuplicateID, IssueReverseClash, IssueSelfLoop, IssueInvalidSpeed, IssueParallelEdges} {
+		edges := edgeIssues[kind]
+		if len(edges) == 0 {
+			continue
+		}
+
+		issue := &Issue{Kind: kind, Count: len(edges), Edges: edges}
+		for _, edge := range edges[:min(len(edges), ValidationExamples)] {
+			issue.Examples = append(issue.Examples, edge.ID)
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	isolated := &Issue{Kind: IssueIsolatedNode}
+	for _, node := range g.sortedNodes() {
+		if len(node.InEdges) == 0 && len(node.OutEdges) == 0 {
+			isolated.Nodes = append(isolated.Nodes, node)
+			if isolated.Count++; isolated.Count <= ValidationExamples {
+				isolated.Examples = append(isolated.Examples, node.ID)
+			}
+		}
+	}
+	if isolated.Count > 0 {
+		report.Issues = append(report.Issues, isolated)
+	}
+	return report
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRecordedClashesAreNotReturnedAsGraphEdges(t *testing.T) {
+	g := NewGraph()
+	g.RecordClashes = true
+	a, _ := g.AddNode("a", Point{0, 0})
+	b, _ := g.AddNode("b", Point{0.001, 0})
+	first, err := g.AddEdge("ab", a, b, 10, []Point{a.Position, b.Position})
+	if err != nil || first == nil {
+		t.Fatalf("first ab: %v, %v", first, err)
+	}
+
+	added, err := g.AddEdge("ab", b, a, 10, []Point{b.Position, a.Position})
+	if err != nil || added != nil {
+		t.Errorf("AddEdge of a taken ID returned %v, %v; want nil, nil", added, err)
+	}
+	if err := g.InsertEdge(NewEdge("ab", a, b, 5, []Point{a.Position, b.Position})); err != nil {
+		t.Errorf("InsertEdge of a taken ID returned %v", err)
+	}
+	if g.Edges["ab"] != first || len(g.Clashes) != 2 {
+		t.Errorf("graph holds %v with %d clashes, want the first ab and 2", g.Edges["ab"], len(g.Clashes))
+	}
+	if issue := g.Validate().Issue(IssueDuplicateID); issue == nil || issue.Count != 2 {
+		t.Errorf("duplicate issue %+v, want 2 edges", issue)
+	}
+
+	g.RecordClashes = false
+	if _, err := g.AddEdge("ab", a, b, 10, []Point{a.Position, b.Position}); !errors.Is(err, ErrEdgeExists) {
+		t.Errorf("AddEdge of a taken ID without recording: %v, want %v", err, ErrEdgeExists)
+	}
+}
+
+func TestValidateReportsRejectedEdges(t *testing.T) {
+	g := NewGraph()
+	g.Rejected = []*Edge{
+		{ID: "malformed"},
+		{ID: "single", Poly: []Point{{1, 1}}},
+		{ID: "zero", Poly: []Point{{1, 1}, {1, 1}}},
+		{ID: "outside", Poly: []Point{{1, 1}, {1, 91}}, Length: 1},
+	}
+	report := g.Validate()
+	if issue := report.Issue(IssueInvalidGeometry); issue == nil || !slices.Equal(issue.Examples, []string{"malformed", "single", "outside"}) {
+		t.Errorf("invalid geometry %+v, want malformed, single and outside", issue)
+	}
+	if issue := report.Issue(IssueZeroLength); issue == nil || !slices.Equal(issue.Examples, []string{"zero"}) {
+		t.Errorf("zero length %+v, want zero", issue)
+	}
+}